@@ -0,0 +1,251 @@
+package goworkouts
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// ManifestName is the name of the manifest file of a FIT bundle
+const ManifestName = "manifest.json"
+
+// BundleFile maps a FIT file of a bundle to its training day
+type BundleFile struct {
+	File        string `json:"file" yaml:"file"`
+	Order       uint32 `json:"order" yaml:"order"`       // training day
+	Position    int    `json:"position" yaml:"position"` // zero based, on the training day
+	Name        string `json:"workoutName" yaml:"workoutName"`
+	Sport       string `json:"sport" yaml:"sport"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// BundleManifest describes the plan a bundle of FIT files was exported from
+type BundleManifest struct {
+	ID          uuid.UUID    `json:"ID" yaml:"ID"`
+	Name        string       `json:"name" yaml:"name"`
+	Duration    uint32       `json:"duration" yaml:"duration"`
+	StartOffset uint32       `json:"startOffset,omitempty" yaml:"startOffset,omitempty"`
+	Description string       `json:"description" yaml:"description"`
+	Weeks       []PlanWeek   `json:"weeks,omitempty" yaml:"weeks,omitempty"`
+	Phases      []PlanPhase  `json:"phases,omitempty" yaml:"phases,omitempty"`
+	Files       []BundleFile `json:"files" yaml:"files"`
+}
+
+// bundleName returns the file name of a workout in a bundle, like
+// 003-1-threshold-intervals.fit
+func bundleName(order uint32, position int, name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		slug = "workout"
+	}
+	return fmt.Sprintf("%03d-%d-%s.fit", order, position+1, slug)
+}
+
+// exportFIT converts every workout to FIT and passes the files and the
+// manifest to write. If options is not nil it returns the FITOptions of the
+// file with the given number in the bundle.
+func (p *TrainingPlan) exportFIT(write func(name string, data []byte) error, options func(n int) FITOptions) error {
+	manifest := BundleManifest{
+		ID:          p.ID,
+		Name:        p.Name,
+		Duration:    p.Duration,
+		StartOffset: p.StartOffset,
+		Description: p.Description,
+		Weeks:       p.Weeks,
+		Phases:      p.Phases,
+		Files:       []BundleFile{},
+	}
+	names := map[string]bool{}
+	for _, day := range p.TrainingDays {
+		for i := range day.Workouts {
+			w := &day.Workouts[i]
+			name := bundleName(day.Order, i, w.Name)
+			if names[name] {
+				return fmt.Errorf("Duplicate workout on day %d: %v", day.Order, name)
+			}
+			names[name] = true
+
+			opts := FITOptions{}
+			if options != nil {
+				opts = options(len(manifest.Files))
+			}
+			data, err := w.MarshalFIT(opts)
+			if err != nil {
+				return err
+			}
+			err = write(name, data)
+			if err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, BundleFile{
+				File:        name,
+				Order:       day.Order,
+				Position:    i,
+				Name:        w.Name,
+				Sport:       w.Sport,
+				Description: w.Description,
+			})
+		}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return write(ManifestName, data)
+}
+
+// dirWriter returns a function that writes files to dir
+func dirWriter(dir string, overwrite bool) (func(name string, data []byte) error, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string, data []byte) error {
+		opts := WriteOptions{Overwrite: OverwriteFail}
+		if overwrite {
+			opts.Overwrite = OverwriteReplace
+		}
+		_, err := WriteFile(filepath.Join(dir, name), data, opts)
+		return err
+	}, nil
+}
+
+// ExportFIT writes every workout of the plan as a FIT file to dir, with a
+// manifest that maps the files to the training days. The directory is
+// created if needed. Existing files are only replaced if overwrite is true.
+func (p *TrainingPlan) ExportFIT(dir string, overwrite bool) error {
+	write, err := dirWriter(dir, overwrite)
+	if err != nil {
+		return err
+	}
+	return p.exportFIT(write, nil)
+}
+
+// ExportFITZip writes the FIT files and manifest of ExportFIT as a zip archive
+// to w
+func (p *TrainingPlan) ExportFITZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	err := p.exportFIT(func(name string, data []byte) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// importFIT rebuilds a plan from a manifest and the FIT files returned by read
+func importFIT(read func(name string) ([]byte, error)) (TrainingPlan, error) {
+	data, err := read(ManifestName)
+	if err != nil {
+		return TrainingPlan{}, err
+	}
+	var manifest BundleManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return TrainingPlan{}, err
+	}
+
+	plan := TrainingPlan{
+		ID:          manifest.ID,
+		Name:        manifest.Name,
+		Duration:    manifest.Duration,
+		StartOffset: manifest.StartOffset,
+		Description: manifest.Description,
+		Weeks:       manifest.Weeks,
+		Phases:      manifest.Phases,
+	}
+	days := map[uint32]int{} // index of a day in TrainingDays
+	for _, file := range manifest.Files {
+		if file.File != filepath.Base(file.File) {
+			return TrainingPlan{}, fmt.Errorf("Invalid file name in manifest: %v", file.File)
+		}
+		data, err := read(file.File)
+		if err != nil {
+			return TrainingPlan{}, err
+		}
+		w, err := DecodeWorkout(bytes.NewReader(data))
+		if err != nil {
+			return TrainingPlan{}, fmt.Errorf("%v: %w", file.File, err)
+		}
+		w.Filename = file.File
+		w.Description = file.Description
+		if file.Sport != "" {
+			w.Sport = file.Sport
+		}
+
+		idx, ok := days[file.Order]
+		if !ok {
+			idx = len(plan.TrainingDays)
+			days[file.Order] = idx
+			plan.TrainingDays = append(plan.TrainingDays, TrainingDay{Order: file.Order})
+		}
+		day := &plan.TrainingDays[idx]
+		if file.Position != len(day.Workouts) {
+			return TrainingPlan{}, fmt.Errorf("Wrong position of %v on day %d", file.File, file.Order)
+		}
+		day.Workouts = append(day.Workouts, w)
+	}
+	return plan, nil
+}
+
+// ImportFITBundle rebuilds a plan from a bundle written by ExportFIT or
+// ExportFITZip. The path is a directory or a zip file.
+func ImportFITBundle(path string) (TrainingPlan, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return TrainingPlan{}, err
+	}
+	if info.IsDir() {
+		return importFIT(func(name string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(path, name))
+		})
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return TrainingPlan{}, err
+	}
+	defer f.Close()
+	return ImportFITZip(f, info.Size())
+}
+
+// ImportFITZip rebuilds a plan from a zip archive written by ExportFITZip
+func ImportFITZip(r io.ReaderAt, size int64) (TrainingPlan, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return TrainingPlan{}, err
+	}
+	return importFIT(func(name string) ([]byte, error) {
+		f, err := zr.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ioutil.ReadAll(f)
+	})
+}
@@ -0,0 +1,111 @@
+package goworkouts
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func bundleTestPlan(t *testing.T) TrainingPlan {
+	w1, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	w2, err := ReadFit("testdata/repeats.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	w1.Description = "Sprints"
+	return TrainingPlan{
+		Name:     "Bundle",
+		Duration: 14,
+		TrainingDays: []TrainingDay{
+			{Order: 2, Workouts: []Workout{w1, w2}},
+			{Order: 9, Workouts: []Workout{w2}},
+		},
+		Weeks: []PlanWeek{{Number: 2, Name: "Recovery week", Recovery: true}},
+	}
+}
+
+func TestBundleName(t *testing.T) {
+	got := bundleName(3, 0, " Threshold: 4x10' (Z4)")
+	if got != "003-1-threshold-4x10-z4.fit" {
+		t.Errorf("Wrong bundle name %v", got)
+	}
+	got = bundleName(12, 1, "")
+	if got != "012-2-workout.fit" {
+		t.Errorf("Wrong bundle name %v", got)
+	}
+}
+
+func checkBundlePlan(t *testing.T, plan TrainingPlan, back TrainingPlan) {
+	if back.Name != plan.Name || back.Duration != plan.Duration || len(back.Weeks) != 1 {
+		t.Errorf("Plan not rebuilt: %+v", back)
+	}
+	if len(back.TrainingDays) != 2 || back.TrainingDays[1].Order != 9 || len(back.TrainingDays[0].Workouts) != 2 {
+		t.Fatalf("Wrong training days %+v", back.TrainingDays)
+	}
+	for i, day := range plan.TrainingDays {
+		for j, w := range day.Workouts {
+			got := back.TrainingDays[i].Workouts[j]
+			if got.Name != w.Name || got.Sport != w.Sport || got.Description != w.Description {
+				t.Errorf("Day %d workout %d: got %v, wanted %v", day.Order, j, got.Name, w.Name)
+			}
+			if len(got.Steps) != len(w.Steps) {
+				t.Errorf("Day %d workout %d: got %d steps, wanted %d", day.Order, j, len(got.Steps), len(w.Steps))
+			}
+		}
+	}
+	if back.TrainingDays[0].Workouts[1].Filename != bundleName(2, 1, plan.TrainingDays[0].Workouts[1].Name) {
+		t.Errorf("Wrong file name %v", back.TrainingDays[0].Workouts[1].Filename)
+	}
+}
+
+func TestExportFIT(t *testing.T) {
+	plan := bundleTestPlan(t)
+	dir := filepath.Join(t.TempDir(), "bundle")
+	err := plan.ExportFIT(dir, false)
+	if err != nil {
+		t.Fatalf("ExportFIT returned an error: %v", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 4 {
+		t.Errorf("Wanted 3 FIT files and a manifest, got %d files", len(files))
+	}
+	err = plan.ExportFIT(dir, false)
+	if err == nil {
+		t.Errorf("Should not overwrite an existing bundle")
+	}
+
+	back, err := ImportFITBundle(dir)
+	if err != nil {
+		t.Fatalf("ImportFITBundle returned an error: %v", err)
+	}
+	checkBundlePlan(t, plan, back)
+}
+
+func TestExportFITZip(t *testing.T) {
+	plan := bundleTestPlan(t)
+	var buf bytes.Buffer
+	err := plan.ExportFITZip(&buf)
+	if err != nil {
+		t.Fatalf("ExportFITZip returned an error: %v", err)
+	}
+	back, err := ImportFITZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("ImportFITZip returned an error: %v", err)
+	}
+	checkBundlePlan(t, plan, back)
+
+	path := filepath.Join(t.TempDir(), "plan.zip")
+	err = os.WriteFile(path, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatalf("Could not write zip file")
+	}
+	back, err = ImportFITBundle(path)
+	if err != nil {
+		t.Fatalf("ImportFITBundle returned an error: %v", err)
+	}
+	checkBundlePlan(t, plan, back)
+}
@@ -0,0 +1,137 @@
+package goworkouts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// genericSport returns true for sports that combine with any other sport,
+// like a generic warmup
+func genericSport(sport string) bool {
+	return sport == "" || sport == "generic"
+}
+
+// concatSport returns the sport of the concatenation of parts. Different
+// sports can only be mixed if one of the parts is a multisport workout.
+func concatSport(parts []Workout) (string, error) {
+	sport := ""
+	mixed := false
+	multi := false
+	for _, part := range parts {
+		switch {
+		case part.Sport == "multi":
+			multi = true
+		case genericSport(part.Sport):
+		case sport == "":
+			sport = part.Sport
+		case part.Sport != sport:
+			mixed = true
+		}
+	}
+	if multi {
+		return "multi", nil
+	}
+	if mixed {
+		return "", errors.New("Cannot concatenate workouts of different sports, use ConcatMultisport")
+	}
+	if sport == "" && len(parts) > 0 {
+		sport = parts[0].Sport
+	}
+	return sport, nil
+}
+
+// Concat appends the steps of the parts into one new workout. The steps are
+// renumbered and the repeat steps are pointed at their new positions. Parts
+// with different sports can only be combined if one of them is a multisport
+// workout, use ConcatMultisport to combine them otherwise.
+func Concat(name string, parts ...Workout) (Workout, error) {
+	sport, err := concatSport(parts)
+	if err != nil {
+		return Workout{}, err
+	}
+	return concat(name, sport, parts)
+}
+
+// ConcatMultisport is like Concat but accepts parts of any sport. The result
+// is a multisport workout.
+func ConcatMultisport(name string, parts ...Workout) (Workout, error) {
+	return concat(name, "multi", parts)
+}
+
+func concat(name string, sport string, parts []Workout) (Workout, error) {
+	var steps []WorkoutStep
+	var targets []int
+	var descriptions []string
+	for i, part := range parts {
+		parttargets, err := repeatTargets(part.Steps)
+		if err != nil {
+			return Workout{}, fmt.Errorf("Part %d: %w", i, err)
+		}
+		offset := len(steps)
+		for j, t := range parttargets {
+			if t >= 0 {
+				parttargets[j] = t + offset
+			}
+		}
+		steps = append(steps, part.Steps...)
+		targets = append(targets, parttargets...)
+		if part.Description != "" {
+			descriptions = append(descriptions, part.Description)
+		}
+	}
+
+	w := Workout{
+		Name:        name,
+		Sport:       sport,
+		Description: strings.Join(descriptions, "\n"),
+	}
+	err := w.commit(steps, targets)
+	if err != nil {
+		return Workout{}, err
+	}
+	return w, nil
+}
+
+// Slice returns a new workout with the steps at positions from to to
+// (inclusive). A repeat step can only be included together with the whole
+// block it repeats.
+func (w *Workout) Slice(from, to int) (Workout, error) {
+	err := w.checkPosition(from)
+	if err != nil {
+		return Workout{}, err
+	}
+	err = w.checkPosition(to)
+	if err != nil {
+		return Workout{}, err
+	}
+	if from > to {
+		return Workout{}, fmt.Errorf("Invalid step range %d-%d", from, to)
+	}
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return Workout{}, err
+	}
+
+	steps := append([]WorkoutStep{}, w.Steps[from:to+1]...)
+	newtargets := append([]int{}, targets[from:to+1]...)
+	for i, t := range newtargets {
+		if t < 0 {
+			continue
+		}
+		if t < from {
+			return Workout{}, stepError(from+i, "DurationValue", "%w: repeats steps outside of %d-%d", ErrInvalidRepeat, from, to)
+		}
+		newtargets[i] = t - from
+	}
+
+	slice := Workout{
+		Name:  w.Name,
+		Sport: w.Sport,
+	}
+	err = slice.commit(steps, newtargets)
+	if err != nil {
+		return Workout{}, err
+	}
+	return slice, nil
+}
@@ -0,0 +1,77 @@
+package goworkouts
+
+import (
+	"testing"
+)
+
+func TestConcat(t *testing.T) {
+	warmup, err := ReadFit("testdata/fitsdk/WorkoutIndividualSteps.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	main, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	w, err := Concat("combined", warmup, main, warmup)
+	if err != nil {
+		t.Fatalf("Concat returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{
+		"_A_", "B1_", "B2_", "_C_",
+		"w10", "45sec", "r75", "@5x6", "@5x4", "cd10",
+		"_A_", "B1_", "B2_", "_C_",
+	})
+	if w.Name != "combined" {
+		t.Errorf("Name is not set correctly")
+	}
+
+	rowing, err := ReadFit("testdata/repeats.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	w, err = Concat("rowing", main, rowing)
+	if err != nil {
+		t.Fatalf("Concat returned an error: %v", err)
+	}
+	if w.Sport != "rowing" {
+		t.Errorf("Sport is not rowing")
+	}
+
+	running := warmup
+	running.Sport = "running"
+	_, err = Concat("mixed", rowing, running)
+	if err == nil {
+		t.Errorf("Mixing sports should have thrown an error")
+	}
+	w, err = ConcatMultisport("mixed", rowing, running)
+	if err != nil {
+		t.Fatalf("ConcatMultisport returned an error: %v", err)
+	}
+	if w.Sport != "multi" {
+		t.Errorf("Sport is not multi")
+	}
+}
+
+func TestSlice(t *testing.T) {
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	block, err := w.Slice(1, 4)
+	if err != nil {
+		t.Fatalf("Slice returned an error: %v", err)
+	}
+	checkSteps(t, block, []string{"45sec", "r75", "@0x6", "@0x4"})
+
+	block, err = w.Slice(1, 2)
+	if err != nil {
+		t.Fatalf("Slice returned an error: %v", err)
+	}
+	checkSteps(t, block, []string{"45sec", "r75"})
+
+	_, err = w.Slice(2, 4)
+	if err == nil {
+		t.Errorf("Slicing through a repeat block should have thrown an error")
+	}
+}
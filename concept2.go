@@ -0,0 +1,495 @@
+package goworkouts
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tormoder/fit"
+)
+
+// A Concept2 PM5 workout is a list of intervals: a fixed time, distance or
+// number of calories of work, followed by a time of rest, with an optional
+// target split and stroke rate. The PM5 has no repeats, no nested blocks and
+// no heart rate or power targets.
+
+// C2Interval is an interval of a Concept2 workout
+type C2Interval struct {
+	Type  string  `json:"type"`            // time, distance or calories
+	Work  float64 `json:"work"`            // in s, m or kcal
+	Rest  float64 `json:"rest"`            // in s
+	Split float64 `json:"split,omitempty"` // target pace in s per 500m
+	Rate  int     `json:"rate,omitempty"`  // target stroke rate in strokes per minute
+}
+
+// C2Workout is a Concept2 PM5 workout definition
+type C2Workout struct {
+	Name      string       `json:"name"`
+	Type      string       `json:"type"` // like FixedTime, FixedDistanceInterval or VariableInterval
+	Intervals []C2Interval `json:"intervals"`
+}
+
+// Limits of the PM5
+const (
+	c2MaxIntervals = 50
+	c2MaxRest      = 595 // 9:55
+)
+
+var c2Durations = map[string]string{
+	"Time":     "time",
+	"Distance": "distance",
+	"Calories": "calories",
+}
+
+var c2Types = map[string]string{
+	"time":     "Time",
+	"distance": "Distance",
+	"calories": "Calorie",
+}
+
+// isRest returns true for steps that are rest between intervals on the PM5
+func isRest(step WorkoutStep) bool {
+	return step.Intensity == "Rest" || step.Intensity == "Recovery"
+}
+
+// c2Split returns the split in s per 500m of a target, if it has one
+func c2Split(step WorkoutStep) (float64, bool) {
+	switch {
+	case isSpeedTarget(step.TargetType) && step.TargetValue == 0 && step.CustomTargetValueHigh > 0:
+		// mm/s
+		speed := float64(step.CustomTargetValueLow+step.CustomTargetValueHigh) / 2 / 1000
+		return math.Round(5000/speed) / 10, true
+	case isPowerTarget(step.TargetType) && step.TargetValue == 0 && step.CustomTargetValueLow > powerOffset:
+		// the Concept2 pace to power formula, watts = 2.8 / pace^3
+		watts := float64(step.CustomTargetValueLow+step.CustomTargetValueHigh)/2 - powerOffset
+		pace := math.Cbrt(2.8 / watts)
+		return math.Round(5000*pace) / 10, true
+	}
+	return 0, false
+}
+
+// c2Interval returns the interval of a work step
+func c2Interval(step WorkoutStep) (C2Interval, error) {
+	idx := int(step.MessageIndex)
+	kind, ok := c2Durations[step.DurationType]
+	if !ok {
+		return C2Interval{}, stepError(idx, "DurationType", "%w: the PM5 cannot end an interval on %v", ErrNotSupported, step.DurationType)
+	}
+	interval := C2Interval{Type: kind, Work: float64(step.DurationValue)}
+	switch step.DurationType {
+	case "Time":
+		interval.Work /= 1000
+	case "Distance":
+		interval.Work /= 100
+	}
+
+	if split, ok := c2Split(step); ok {
+		interval.Split = split
+		return interval, nil
+	}
+	switch {
+	case step.TargetType == "Open":
+	case isSpeedTarget(step.TargetType) && step.TargetValue == 0:
+		// a speed target without values, which sets no target
+	case isSpeedTarget(step.TargetType):
+		return interval, stepError(idx, "TargetValue", "%w: the PM5 has no speed zones", ErrNotSupported)
+	case step.TargetType == "Cadence":
+		interval.Rate = int(step.TargetValue)
+		if step.TargetValue == 0 {
+			interval.Rate = int(math.Round(float64(step.CustomTargetValueLow+step.CustomTargetValueHigh) / 2))
+		}
+	case isHRTarget(step.TargetType):
+		return interval, stepError(idx, "TargetType", "%w: the PM5 has no heart rate targets", ErrNotSupported)
+	case isPowerTarget(step.TargetType):
+		return interval, stepError(idx, "TargetType", "%w: the PM5 only has power targets in W", ErrNotSupported)
+	default:
+		return interval, stepError(idx, "TargetType", "%w: the PM5 has no %v target", ErrNotSupported, step.TargetType)
+	}
+	return interval, nil
+}
+
+// c2WorkoutType returns the PM5 workout type of the intervals
+func c2WorkoutType(intervals []C2Interval) string {
+	first := intervals[0]
+	if len(intervals) == 1 && first.Rest == 0 {
+		return "Fixed" + c2Types[first.Type]
+	}
+	for _, interval := range intervals[1:] {
+		if interval.Type != first.Type || interval.Work != first.Work || interval.Rest != first.Rest {
+			return "VariableInterval"
+		}
+	}
+	return "Fixed" + c2Types[first.Type] + "Interval"
+}
+
+// concept2Fidelity reports what ToConcept2 does not keep
+func (w *Workout) concept2Fidelity(format string) FidelityReport {
+	report := FidelityReport{Format: format}
+	workoutFields := []struct {
+		name string
+		set  bool
+	}{
+		{"Description", w.Description != ""},
+		{"SubSport", w.SubSport != ""},
+		{"PoolLength", w.PoolLength != 0},
+		{"Capabilities", w.Capabilities != 0},
+		{"Extensions", !w.Extensions.empty()},
+	}
+	for _, field := range workoutFields {
+		if field.set {
+			report.add(-1, field.name, LossDropped, "Concept2 workouts have no %v", field.name)
+		}
+	}
+	for i, step := range w.Steps {
+		if step.WktStepName != "" {
+			report.add(i, "WktStepName", LossDropped, "PM5 intervals have no name")
+		}
+		if step.Notes != "" {
+			report.add(i, "Notes", LossDropped, "PM5 intervals have no notes")
+		}
+		if !step.Extensions.empty() {
+			report.add(i, "Extensions", LossDropped, "PM5 intervals have no FIT extensions")
+		}
+		if int(step.MessageIndex) != i {
+			report.add(i, "MessageIndex", LossRewritten, "Concept2 numbers the steps in order")
+		}
+		if isRepeat(step) {
+			report.add(i, "DurationType", LossRewritten, "The PM5 has no repeats, the intervals are written out")
+			continue
+		}
+		switch {
+		case step.Intensity == "Recovery":
+			report.add(i, "Intensity", LossRewritten, "Recovery is written as rest")
+		case !isRest(step) && step.Intensity != "Active":
+			report.add(i, "Intensity", LossRewritten, "%v is written as work", step.Intensity)
+		}
+		if isRest(step) {
+			if step.TargetType != "Open" {
+				report.add(i, "TargetType", LossDropped, "PM5 rest has no target")
+			}
+			continue
+		}
+		_, split := c2Split(step)
+		switch {
+		case split && isPowerTarget(step.TargetType):
+			report.add(i, "TargetValue", LossApproximated, "Power is written as the split of that power")
+		case split && step.CustomTargetValueLow != step.CustomTargetValueHigh:
+			report.add(i, "TargetValue", LossApproximated, "The middle of the range is written as the target split")
+		case step.TargetType == "Cadence" && step.TargetValue > 0:
+			report.add(i, "TargetValue", LossRewritten, "The PM5 has no cadence zones, the value is written as the stroke rate")
+		case step.TargetType == "Cadence" && step.CustomTargetValueLow != step.CustomTargetValueHigh:
+			report.add(i, "TargetValue", LossApproximated, "The middle of the range is written as the stroke rate")
+		case !split && step.TargetType != "Cadence" && step.TargetType != "Open":
+			report.add(i, "TargetType", LossDropped, "The %v target has no PM5 target", step.TargetType)
+		}
+		if split && step.TargetType != "Speed" {
+			report.add(i, "TargetType", LossRewritten, "%v is written as a target split", step.TargetType)
+		}
+	}
+	if format == "concept2csv" && w.Name != "" {
+		report.add(-1, "Name", LossDropped, "Concept2 CSV has no workout name")
+	}
+	return report
+}
+
+// ToConcept2 returns the workout as a Concept2 PM5 workout definition. The
+// repeats are written out, and rest and recovery steps become the rest of
+// the interval before them. Speed and power in W targets become a target
+// split, cadence targets a stroke rate. Workouts the PM5 cannot do, like
+// workouts of other sports, nested repeats, more than 50 intervals or heart
+// rate targets, return an error that wraps ErrNotSupported.
+func (w *Workout) ToConcept2() (C2Workout, error) {
+	if w.Sport != "rowing" {
+		return C2Workout{}, fmt.Errorf("%w: Concept2 workouts are for rowing, not %q", ErrNotSupported, w.Sport)
+	}
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return C2Workout{}, err
+	}
+	err = checkNesting(w.Steps, targets)
+	if err != nil {
+		return C2Workout{}, err
+	}
+	for i, from := range targets {
+		for j := from + 1; from >= 0 && j < i; j++ {
+			if targets[j] >= 0 {
+				return C2Workout{}, stepError(i, "DurationValue", "%w: the PM5 cannot nest repeats", ErrNotSupported)
+			}
+		}
+	}
+	steps, err := w.Expand()
+	if err != nil {
+		return C2Workout{}, err
+	}
+
+	c2 := C2Workout{Name: w.Name}
+	for _, step := range steps {
+		if isRest(step) {
+			idx := int(step.MessageIndex)
+			n := len(c2.Intervals)
+			switch {
+			case n == 0:
+				return C2Workout{}, stepError(idx, "Intensity", "%w: a PM5 workout cannot start with rest", ErrNotSupported)
+			case step.DurationType != "Time":
+				return C2Workout{}, stepError(idx, "DurationType", "%w: PM5 rest is a time", ErrNotSupported)
+			}
+			c2.Intervals[n-1].Rest += float64(step.DurationValue) / 1000
+			if c2.Intervals[n-1].Rest > c2MaxRest {
+				return C2Workout{}, stepError(idx, "DurationValue", "%w: PM5 rest is at most 9:55", ErrNotSupported)
+			}
+			continue
+		}
+		interval, err := c2Interval(step)
+		if err != nil {
+			return C2Workout{}, err
+		}
+		c2.Intervals = append(c2.Intervals, interval)
+	}
+	switch {
+	case len(c2.Intervals) == 0:
+		return C2Workout{}, fmt.Errorf("%w: a PM5 workout needs at least one interval", ErrNotSupported)
+	case len(c2.Intervals) > c2MaxIntervals:
+		return C2Workout{}, fmt.Errorf("%w: the PM5 has at most %d intervals, the workout has %d", ErrNotSupported, c2MaxIntervals, len(c2.Intervals))
+	}
+	c2.Type = c2WorkoutType(c2.Intervals)
+	return c2, nil
+}
+
+// Workout returns the Concept2 workout as a rowing workout. Every interval
+// becomes a work step, followed by a rest step if it has rest. FIT steps
+// have a single target, so the target split wins over the stroke rate.
+func (c2 *C2Workout) Workout() (Workout, error) {
+	w := Workout{Name: c2.Name, Sport: "rowing"}
+	for i, interval := range c2.Intervals {
+		step := newWorkoutStep()
+		step.MessageIndex = fit.MessageIndex(len(w.Steps))
+		step.Intensity = "Active"
+		switch interval.Type {
+		case "time":
+			step.DurationType = "Time"
+			step.DurationValue = uint32(math.Round(interval.Work * 1000))
+		case "distance":
+			step.DurationType = "Distance"
+			step.DurationValue = uint32(math.Round(interval.Work * 100))
+		case "calories":
+			step.DurationType = "Calories"
+			step.DurationValue = uint32(math.Round(interval.Work))
+		default:
+			return Workout{}, fmt.Errorf("Interval %d: unknown interval type %q", i, interval.Type)
+		}
+		step.TargetType = "Open"
+		switch {
+		case interval.Split > 0:
+			// mm/s
+			speed := uint32(math.Round(500 / interval.Split * 1000))
+			step.TargetType = "Speed"
+			step.CustomTargetValueLow, step.CustomTargetValueHigh = speed, speed
+		case interval.Rate > 0:
+			step.TargetType = "Cadence"
+			step.CustomTargetValueLow, step.CustomTargetValueHigh = uint32(interval.Rate), uint32(interval.Rate)
+		}
+		w.Steps = append(w.Steps, step)
+
+		if interval.Rest > 0 {
+			rest := newWorkoutStep()
+			rest.MessageIndex = fit.MessageIndex(len(w.Steps))
+			rest.DurationType = "Time"
+			rest.DurationValue = uint32(math.Round(interval.Rest * 1000))
+			rest.TargetType = "Open"
+			rest.Intensity = "Rest"
+			w.Steps = append(w.Steps, rest)
+		}
+	}
+	return w, nil
+}
+
+// ToConcept2JSON exports to the JSON of a Concept2 workout definition, see
+// ToConcept2
+func (w *Workout) ToConcept2JSON() ([]byte, error) {
+	c2, err := w.ToConcept2()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(c2)
+}
+
+// FromConcept2JSON returns workout from the JSON of a Concept2 workout
+// definition
+func FromConcept2JSON(s string) (Workout, error) {
+	var c2 C2Workout
+	err := json.Unmarshal([]byte(s), &c2)
+	if err != nil {
+		return Workout{}, err
+	}
+	return c2.Workout()
+}
+
+// c2Header is the header of Concept2 CSV
+var c2Header = []string{"type", "work", "rest", "split", "rate"}
+
+// formatC2Time formats seconds as m:ss or h:mm:ss, with tenths if needed
+func formatC2Time(seconds float64) string {
+	tenths := int(math.Round(seconds * 10))
+	h, m, s, t := tenths/36000, tenths/600%60, tenths/10%60, tenths%10
+	var text string
+	if h > 0 {
+		text = fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	} else {
+		text = fmt.Sprintf("%d:%02d", m, s)
+	}
+	if t > 0 {
+		text += fmt.Sprintf(".%d", t)
+	}
+	return text
+}
+
+// parseC2Time parses h:mm:ss, m:ss or s, with optional decimals
+func parseC2Time(text string) (float64, error) {
+	var seconds float64
+	for _, part := range strings.Split(text, ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid time %q", text)
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+// ToConcept2CSV exports to Concept2 CSV, with a header and a line per
+// interval: type, work, rest, split and rate. Work is a time for time
+// intervals, rest and split are times.
+func (w *Workout) ToConcept2CSV() ([]byte, error) {
+	c2, err := w.ToConcept2()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	_ = cw.Write(c2Header)
+	for _, interval := range c2.Intervals {
+		record := []string{interval.Type, strconv.FormatFloat(interval.Work, 'f', -1, 64), formatC2Time(interval.Rest), "", ""}
+		if interval.Type == "time" {
+			record[1] = formatC2Time(interval.Work)
+		}
+		if interval.Split > 0 {
+			record[3] = formatC2Time(interval.Split)
+		}
+		if interval.Rate > 0 {
+			record[4] = strconv.Itoa(interval.Rate)
+		}
+		_ = cw.Write(record)
+	}
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
+
+// FromConcept2CSV returns workout from Concept2 CSV, see ToConcept2CSV
+func FromConcept2CSV(s string) (Workout, error) {
+	records, err := csv.NewReader(strings.NewReader(s)).ReadAll()
+	if err != nil {
+		return Workout{}, err
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(c2Header, ",") {
+		return Workout{}, fmt.Errorf("Concept2 CSV must start with the header %v", strings.Join(c2Header, ","))
+	}
+	var c2 C2Workout
+	for n, record := range records[1:] {
+		interval := C2Interval{Type: record[0]}
+		if interval.Type == "time" {
+			interval.Work, err = parseC2Time(record[1])
+		} else {
+			interval.Work, err = strconv.ParseFloat(record[1], 64)
+		}
+		if err == nil && record[2] != "" {
+			interval.Rest, err = parseC2Time(record[2])
+		}
+		if err == nil && record[3] != "" {
+			interval.Split, err = parseC2Time(record[3])
+		}
+		if err == nil && record[4] != "" {
+			interval.Rate, err = strconv.Atoi(record[4])
+		}
+		if err != nil {
+			return Workout{}, fmt.Errorf("Line %d: %w", n+2, err)
+		}
+		c2.Intervals = append(c2.Intervals, interval)
+	}
+	return c2.Workout()
+}
+
+// concept2Format is the JSON of a Concept2 workout definition
+type concept2Format struct{}
+
+func (concept2Format) Name() string         { return "concept2" }
+func (concept2Format) Extensions() []string { return []string{".json"} }
+
+func (concept2Format) Detect(data []byte) bool {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) != nil {
+		return false
+	}
+	_, ok := object["intervals"]
+	return ok
+}
+
+func (concept2Format) Decode(r io.Reader) (Workout, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Workout{}, err
+	}
+	return FromConcept2JSON(string(data))
+}
+
+func (concept2Format) Encode(wr io.Writer, w *Workout) error {
+	data, err := w.ToConcept2JSON()
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(data)
+	return err
+}
+
+// Fidelity lists what the export loses. Workouts the PM5 cannot do are not
+// in the report, ToConcept2 returns an error for them.
+func (concept2Format) Fidelity(w *Workout) FidelityReport {
+	return w.concept2Fidelity("concept2")
+}
+
+// concept2CSVFormat is Concept2 CSV
+type concept2CSVFormat struct{}
+
+func (concept2CSVFormat) Name() string         { return "concept2csv" }
+func (concept2CSVFormat) Extensions() []string { return []string{".csv"} }
+
+func (concept2CSVFormat) Detect(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(strings.Join(c2Header, ",")))
+}
+
+func (concept2CSVFormat) Decode(r io.Reader) (Workout, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Workout{}, err
+	}
+	return FromConcept2CSV(string(data))
+}
+
+func (concept2CSVFormat) Encode(wr io.Writer, w *Workout) error {
+	data, err := w.ToConcept2CSV()
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(data)
+	return err
+}
+
+// Fidelity lists what the export loses, see concept2Format
+func (concept2CSVFormat) Fidelity(w *Workout) FidelityReport {
+	return w.concept2Fidelity("concept2csv")
+}
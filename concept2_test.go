@@ -0,0 +1,165 @@
+package goworkouts
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func concept2Workout() Workout {
+	return Workout{
+		Name:  "4x2k",
+		Sport: "rowing",
+		Steps: []WorkoutStep{
+			{MessageIndex: 0, DurationType: "Time", DurationValue: 600000, TargetType: "Cadence", CustomTargetValueLow: 20, CustomTargetValueHigh: 20, Intensity: "Active"},
+			{MessageIndex: 1, DurationType: "Distance", DurationValue: 200000, TargetType: "Speed", CustomTargetValueLow: 4167, CustomTargetValueHigh: 4167, Intensity: "Active"},
+			{MessageIndex: 2, DurationType: "Time", DurationValue: 180000, TargetType: "Open", Intensity: "Rest"},
+			{MessageIndex: 3, DurationType: "RepeatUntilStepsCmplt", DurationValue: 1, TargetType: "Open", TargetValue: 4, Intensity: "Active"},
+			{MessageIndex: 4, DurationType: "Time", DurationValue: 300000, TargetType: "Power", CustomTargetValueLow: 1202, CustomTargetValueHigh: 1203, Intensity: "Active"},
+		},
+	}
+}
+
+func TestConcept2(t *testing.T) {
+	w := concept2Workout()
+	c2, err := w.ToConcept2()
+	if err != nil {
+		t.Fatalf("ToConcept2 returned an error: %v", err)
+	}
+	if c2.Type != "VariableInterval" || len(c2.Intervals) != 6 {
+		t.Fatalf("Wrong workout %+v", c2)
+	}
+	want := []C2Interval{
+		{Type: "time", Work: 600, Rate: 20},
+		{Type: "distance", Work: 2000, Rest: 180, Split: 120},
+		{Type: "time", Work: 300, Split: 120},
+	}
+	got := []C2Interval{c2.Intervals[0], c2.Intervals[4], c2.Intervals[5]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong intervals\nGot:  %+v\nWant: %+v", got, want)
+	}
+
+	data, err := w.ToConcept2JSON()
+	if err != nil {
+		t.Fatalf("ToConcept2JSON returned an error: %v", err)
+	}
+	checkGoldenJSON(t, "concept2.json", data)
+	csv, err := w.ToConcept2CSV()
+	if err != nil {
+		t.Fatalf("ToConcept2CSV returned an error: %v", err)
+	}
+	checkGolden(t, "concept2.csv", csv)
+
+	fromJSON, err := FromConcept2JSON(string(data))
+	if err != nil {
+		t.Fatalf("FromConcept2JSON returned an error: %v", err)
+	}
+	fromCSV, err := FromConcept2CSV(string(csv))
+	if err != nil {
+		t.Fatalf("FromConcept2CSV returned an error: %v", err)
+	}
+	fromCSV.Name = w.Name
+	if !reflect.DeepEqual(fromCSV, fromJSON) {
+		t.Errorf("CSV and JSON differ\nCSV:  %+v\nJSON: %+v", fromCSV, fromJSON)
+	}
+	if len(fromJSON.Steps) != 10 || fromJSON.Steps[2].Intensity != "Rest" || fromJSON.Steps[2].DurationValue != 180000 {
+		t.Errorf("Wrong steps %+v", fromJSON.Steps)
+	}
+	d1, _ := w.PlannedDuration(0)
+	d2, _ := fromJSON.PlannedDuration(0)
+	if d1 != d2 {
+		t.Errorf("Got duration %v, wanted %v", d2, d1)
+	}
+
+	for name, data := range map[string][]byte{"concept2": data, "concept2csv": csv} {
+		f, err := DetectFormat(data, "")
+		if err != nil || f.Name() != name {
+			t.Errorf("%v is not detected: %v", name, err)
+		}
+	}
+	report, _ := Fidelity(&w, "concept2")
+	if len(report.Losses) != 3 || report.Losses[0].Step != 3 {
+		t.Errorf("Wrong report %+v", report.Losses)
+	}
+}
+
+func TestConcept2Types(t *testing.T) {
+	tests := []struct {
+		steps []WorkoutStep
+		want  string
+	}{
+		{[]WorkoutStep{{DurationType: "Time", DurationValue: 1800000, TargetType: "Open", Intensity: "Active"}}, "FixedTime"},
+		{[]WorkoutStep{{DurationType: "Calories", DurationValue: 300, TargetType: "Open", Intensity: "Active"}}, "FixedCalorie"},
+		{[]WorkoutStep{
+			{MessageIndex: 0, DurationType: "Distance", DurationValue: 50000, TargetType: "Open", Intensity: "Active"},
+			{MessageIndex: 1, DurationType: "Time", DurationValue: 60000, TargetType: "Open", Intensity: "Recovery"},
+			{MessageIndex: 2, DurationType: "RepeatUntilStepsCmplt", DurationValue: 0, TargetType: "Open", TargetValue: 8, Intensity: "Active"},
+		}, "FixedDistanceInterval"},
+	}
+	for _, test := range tests {
+		w := Workout{Sport: "rowing", Steps: test.steps}
+		c2, err := w.ToConcept2()
+		if err != nil {
+			t.Errorf("ToConcept2 returned an error: %v", err)
+			continue
+		}
+		if c2.Type != test.want {
+			t.Errorf("Got type %v, wanted %v", c2.Type, test.want)
+		}
+	}
+}
+
+func TestConcept2Errors(t *testing.T) {
+	nested, err := ReadFit("testdata/nestedrepeats.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error: %v", err)
+	}
+	nested.Sport = "rowing"
+	long := Workout{Sport: "rowing", Steps: []WorkoutStep{
+		{DurationType: "Time", DurationValue: 60000, TargetType: "Open", Intensity: "Active"},
+		{MessageIndex: 1, DurationType: "RepeatUntilStepsCmplt", TargetType: "Open", TargetValue: 51, Intensity: "Active"},
+	}}
+	step := func(s WorkoutStep) Workout {
+		return Workout{Sport: "rowing", Steps: []WorkoutStep{s}}
+	}
+	tests := []struct {
+		name string
+		w    Workout
+		step bool // the error is a StepError
+	}{
+		{"nested repeats", nested, true},
+		{"cycling", Workout{Sport: "cycling", Steps: long.Steps[:1]}, false},
+		{"too many intervals", long, false},
+		{"heart rate", step(WorkoutStep{DurationType: "Time", DurationValue: 60000, TargetType: "HeartRate", TargetValue: 2, Intensity: "Active"}), true},
+		{"power in percent", step(WorkoutStep{DurationType: "Time", DurationValue: 60000, TargetType: "Power", CustomTargetValueLow: 90, CustomTargetValueHigh: 100, Intensity: "Active"}), true},
+		{"open", step(WorkoutStep{DurationType: "Open", TargetType: "Open", Intensity: "Active"}), true},
+		{"rest first", step(WorkoutStep{DurationType: "Time", DurationValue: 60000, TargetType: "Open", Intensity: "Rest"}), true},
+	}
+	for _, test := range tests {
+		_, err := test.w.ToConcept2()
+		if !errors.Is(err, ErrNotSupported) {
+			t.Errorf("%v: got %v, wanted ErrNotSupported", test.name, err)
+		}
+		var stepErr *StepError
+		if errors.As(err, &stepErr) != test.step {
+			t.Errorf("%v: got %v, StepError %v", test.name, err, test.step)
+		}
+	}
+
+	csvTests := []string{
+		"interval,work\ntime,1:00",
+		"type,work,rest,split,rate\ntime,one,0:00,,",
+		"type,work,rest,split,rate\nmeters,500,0:00,,",
+	}
+	for _, test := range csvTests {
+		_, err := FromConcept2CSV(test)
+		if err == nil {
+			t.Errorf("Expected an error for %q", test)
+		}
+	}
+	_, err = FromConcept2CSV("type,work,rest,split,rate\ntime,1:00,0:00,,\ntime,2:00,0:00:x,,")
+	if err == nil || !strings.Contains(err.Error(), "Line 3") {
+		t.Errorf("The error does not name the line: %v", err)
+	}
+}
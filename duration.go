@@ -0,0 +1,119 @@
+package goworkouts
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxExpandedSteps limits the number of steps Expand returns, so workouts with
+// absurd repeat counts cannot exhaust memory
+const maxExpandedSteps = 100000
+
+// DefaultSpeeds are typical speeds in m/s, used to estimate the duration of
+// distance steps when no speed is given
+var DefaultSpeeds = map[string]float64{
+	"generic":         3.0,
+	"running":         3.0,
+	"cycling":         8.0,
+	"swimming":        1.0,
+	"walking":         1.4,
+	"crosscountryski": 4.0,
+	"rowing":          4.0,
+	"hiking":          1.2,
+	"inlineskate":     5.0,
+	"iceskate":        6.0,
+	"hitt":            3.0,
+}
+
+// defaultSpeed returns the default speed of a sport, falling back to generic
+func defaultSpeed(sport string) float64 {
+	speed, ok := DefaultSpeeds[sport]
+	if !ok {
+		speed = DefaultSpeeds["generic"]
+	}
+	return speed
+}
+
+// repeatCount returns how many times a repeat step repeats its block. Only
+// RepeatUntilStepsCmplt has a known count, the other repeat types depend on
+// the athlete and are counted as a single pass.
+func repeatCount(step WorkoutStep) uint32 {
+	if step.DurationType == "RepeatUntilStepsCmplt" && step.TargetValue > 0 {
+		return step.TargetValue
+	}
+	return 1
+}
+
+// Expand returns the steps of the workout in the order they are done, with the
+// repeat steps unrolled
+func (w *Workout) Expand() ([]WorkoutStep, error) {
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return nil, err
+	}
+	err = checkNesting(w.Steps, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []WorkoutStep
+	done := make([]uint32, len(w.Steps))
+	for pc := 0; pc < len(w.Steps); {
+		step := w.Steps[pc]
+		if targets[pc] < 0 {
+			if len(steps) >= maxExpandedSteps {
+				return nil, fmt.Errorf("Workout has more than %d steps when repeats are expanded", maxExpandedSteps)
+			}
+			steps = append(steps, step)
+			pc++
+			continue
+		}
+		done[pc]++
+		if done[pc] < repeatCount(step) {
+			pc = targets[pc]
+			continue
+		}
+		done[pc] = 0
+		pc++
+	}
+	return steps, nil
+}
+
+// StepDuration estimates how long a step takes. Distance steps are converted
+// with speed in m/s. Steps that end on a condition, like open or heart rate
+// steps, have no known duration and return 0.
+func StepDuration(step WorkoutStep, speed float64) (time.Duration, error) {
+	switch step.DurationType {
+	case "Time", "TimeOnly", "RepetitionTime":
+		return time.Duration(step.DurationValue) * time.Millisecond, nil
+	case "Distance":
+		if speed <= 0 {
+			return 0, stepError(int(step.MessageIndex), "DurationValue", "Need a speed to estimate the duration")
+		}
+		meters := float64(step.DurationValue) / 100
+		return time.Duration(meters / speed * float64(time.Second)), nil
+	}
+	return 0, nil
+}
+
+// PlannedDuration estimates the total duration of the workout with the
+// repeats expanded. Distance steps are converted with speed in m/s, if speed
+// is 0 the default speed of the sport is used (see DefaultSpeeds).
+func (w *Workout) PlannedDuration(speed float64) (time.Duration, error) {
+	if speed == 0 {
+		speed = defaultSpeed(w.Sport)
+	}
+	steps, err := w.Expand()
+	if err != nil {
+		return 0, err
+	}
+	var total time.Duration
+	for _, step := range steps {
+		d, err := StepDuration(step, speed)
+		if err != nil {
+			return 0, err
+		}
+		total += d
+	}
+	return total, nil
+}
@@ -0,0 +1,286 @@
+package goworkouts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tormoder/fit"
+)
+
+// The editing methods in this file work on step positions (the index in
+// Workout.Steps), not on MessageIndex values. After every successful edit the
+// steps are renumbered so that MessageIndex equals the position again, and the
+// DurationValue of every repeat step is rewritten to point at the first step
+// of its block.
+
+// isRepeat returns true if the step repeats a block of earlier steps
+func isRepeat(step WorkoutStep) bool {
+	return strings.HasPrefix(step.DurationType, "RepeatUntil")
+}
+
+// repeatTargets returns for every step the position of the first step of the
+// block it repeats, or -1 if the step is not a repeat step
+func repeatTargets(steps []WorkoutStep) ([]int, error) {
+	targets := make([]int, len(steps))
+	for i, step := range steps {
+		targets[i] = -1
+		if !isRepeat(step) {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if uint32(steps[j].MessageIndex) == step.DurationValue {
+				targets[i] = j
+				break
+			}
+		}
+		if targets[i] < 0 {
+			return nil, &StepError{Index: i, Field: "DurationValue", Err: fmt.Errorf("%w: repeat of unknown step %d", ErrStepNotFound, step.DurationValue)}
+		}
+	}
+	return targets, nil
+}
+
+// checkNesting verifies that repeat blocks are either disjoint or nested
+func checkNesting(steps []WorkoutStep, targets []int) error {
+	for i, from := range targets {
+		if from < 0 {
+			continue
+		}
+		if from >= i {
+			return stepError(i, "DurationValue", "%w: repeat step must follow the steps it repeats", ErrInvalidRepeat)
+		}
+		if isRepeat(steps[from]) {
+			return stepError(i, "DurationValue", "%w: block starts with repeat step %d", ErrInvalidRepeat, from)
+		}
+		for j, other := range targets {
+			if j == i || other < 0 {
+				continue
+			}
+			// blocks [from, i] and [other, j]
+			if from > j || other > i {
+				continue
+			}
+			inside := from <= other && j < i
+			outside := other <= from && i < j
+			if !inside && !outside {
+				return stepError(i, "DurationValue", "%w: overlaps repeat step %d without nesting", ErrInvalidRepeat, j)
+			}
+		}
+	}
+	return nil
+}
+
+// renumber sets MessageIndex to the step position and points the repeat
+// steps at the first step of their block
+func renumber(steps []WorkoutStep, targets []int) {
+	for i := range steps {
+		steps[i].MessageIndex = fit.MessageIndex(i)
+		if targets[i] >= 0 {
+			steps[i].DurationValue = uint32(targets[i])
+		}
+	}
+}
+
+// commit validates the edited steps and stores them on the workout
+func (w *Workout) commit(steps []WorkoutStep, targets []int) error {
+	err := checkNesting(steps, targets)
+	if err != nil {
+		return err
+	}
+	renumber(steps, targets)
+	w.Steps = steps
+	return nil
+}
+
+func (w *Workout) checkPosition(idx int) error {
+	if idx < 0 || idx >= len(w.Steps) {
+		return &StepError{Index: idx, Err: ErrStepNotFound}
+	}
+	return nil
+}
+
+// Renumber sets MessageIndex to the position of each step and rewrites the
+// repeat steps accordingly. It returns an error if a repeat step refers to a
+// step that does not exist or if repeat blocks overlap.
+func (w *Workout) Renumber() error {
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return err
+	}
+	steps := append([]WorkoutStep{}, w.Steps...)
+	return w.commit(steps, targets)
+}
+
+// InsertStep inserts a step at position idx. Inserting at the position of the
+// first step of a repeat block places the step before that block. Repeat steps
+// cannot be inserted this way, use WrapInRepeat instead.
+func (w *Workout) InsertStep(idx int, step WorkoutStep) error {
+	if idx < 0 || idx > len(w.Steps) {
+		return &StepError{Index: idx, Err: ErrStepNotFound}
+	}
+	if isRepeat(step) {
+		return stepError(idx, "DurationType", "Use WrapInRepeat to add repeat steps")
+	}
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return err
+	}
+
+	steps := make([]WorkoutStep, 0, len(w.Steps)+1)
+	newtargets := make([]int, 0, len(w.Steps)+1)
+	steps = append(steps, w.Steps[:idx]...)
+	steps = append(steps, step)
+	steps = append(steps, w.Steps[idx:]...)
+	newtargets = append(newtargets, targets[:idx]...)
+	newtargets = append(newtargets, -1)
+	newtargets = append(newtargets, targets[idx:]...)
+	for i, t := range newtargets {
+		if t >= idx {
+			newtargets[i] = t + 1
+		}
+	}
+
+	return w.commit(steps, newtargets)
+}
+
+// RemoveStep removes the step at position idx. Removing a repeat step is the
+// same as Unwrap. Removing the only step of a repeat block is rejected.
+func (w *Workout) RemoveStep(idx int) error {
+	err := w.checkPosition(idx)
+	if err != nil {
+		return err
+	}
+	if isRepeat(w.Steps[idx]) {
+		return w.Unwrap(idx)
+	}
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return err
+	}
+
+	steps := make([]WorkoutStep, 0, len(w.Steps)-1)
+	newtargets := make([]int, 0, len(w.Steps)-1)
+	steps = append(steps, w.Steps[:idx]...)
+	steps = append(steps, w.Steps[idx+1:]...)
+	newtargets = append(newtargets, targets[:idx]...)
+	newtargets = append(newtargets, targets[idx+1:]...)
+	for i, t := range newtargets {
+		// a block that started with the removed step now starts with the
+		// step after it, which takes over position idx
+		if t == idx && i == idx {
+			return stepError(idx, "", "%w: removing the step would leave repeat step %d empty", ErrInvalidRepeat, i+1)
+		}
+		if t > idx {
+			newtargets[i] = t - 1
+		}
+	}
+
+	return w.commit(steps, newtargets)
+}
+
+// MoveStep moves the step at position from so that it ends up at position to.
+// Repeat steps cannot be moved, use Unwrap and WrapInRepeat instead.
+func (w *Workout) MoveStep(from, to int) error {
+	err := w.checkPosition(from)
+	if err != nil {
+		return err
+	}
+	err = w.checkPosition(to)
+	if err != nil {
+		return err
+	}
+	step := w.Steps[from]
+	if isRepeat(step) {
+		return stepError(from, "DurationType", "Repeat steps cannot be moved")
+	}
+
+	moved := *w
+	moved.Steps = append([]WorkoutStep{}, w.Steps...)
+	err = moved.RemoveStep(from)
+	if err != nil {
+		return err
+	}
+	err = moved.InsertStep(to, step)
+	if err != nil {
+		return err
+	}
+	w.Steps = moved.Steps
+	return nil
+}
+
+// WrapInRepeat repeats the steps at positions from to to (inclusive) n times,
+// by adding a RepeatUntilStepsCmplt step directly after step to. The new block
+// must not partially overlap an existing repeat block.
+func (w *Workout) WrapInRepeat(from, to int, n uint32) error {
+	err := w.checkPosition(from)
+	if err != nil {
+		return err
+	}
+	err = w.checkPosition(to)
+	if err != nil {
+		return err
+	}
+	if from > to {
+		return fmt.Errorf("Invalid step range %d-%d", from, to)
+	}
+	if n < 1 {
+		return errors.New("Number of repeats must be at least 1")
+	}
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return err
+	}
+
+	repeat := newWorkoutStep()
+	repeat.DurationType = "RepeatUntilStepsCmplt"
+	repeat.TargetType = "Open"
+	repeat.TargetValue = n
+	repeat.Intensity = "Active"
+
+	steps := make([]WorkoutStep, 0, len(w.Steps)+1)
+	newtargets := make([]int, 0, len(w.Steps)+1)
+	steps = append(steps, w.Steps[:to+1]...)
+	steps = append(steps, repeat)
+	steps = append(steps, w.Steps[to+1:]...)
+	newtargets = append(newtargets, targets[:to+1]...)
+	newtargets = append(newtargets, from)
+	newtargets = append(newtargets, targets[to+1:]...)
+	for i, t := range newtargets {
+		if i != to+1 && t > to {
+			newtargets[i] = t + 1
+		}
+	}
+
+	return w.commit(steps, newtargets)
+}
+
+// Unwrap removes the repeat step at position repeatIdx, leaving the steps of
+// its block in place to be done once
+func (w *Workout) Unwrap(repeatIdx int) error {
+	err := w.checkPosition(repeatIdx)
+	if err != nil {
+		return err
+	}
+	if !isRepeat(w.Steps[repeatIdx]) {
+		return stepError(repeatIdx, "DurationType", "Not a repeat step")
+	}
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return err
+	}
+
+	steps := make([]WorkoutStep, 0, len(w.Steps)-1)
+	newtargets := make([]int, 0, len(w.Steps)-1)
+	steps = append(steps, w.Steps[:repeatIdx]...)
+	steps = append(steps, w.Steps[repeatIdx+1:]...)
+	newtargets = append(newtargets, targets[:repeatIdx]...)
+	newtargets = append(newtargets, targets[repeatIdx+1:]...)
+	for i, t := range newtargets {
+		if t > repeatIdx {
+			newtargets[i] = t - 1
+		}
+	}
+
+	return w.commit(steps, newtargets)
+}
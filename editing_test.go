@@ -0,0 +1,159 @@
+package goworkouts
+
+import (
+	"fmt"
+	"testing"
+)
+
+// stepNames returns the step names, repeat steps as "@<target>x<n>"
+func stepNames(w Workout) []string {
+	var names []string
+	for _, step := range w.Steps {
+		if isRepeat(step) {
+			names = append(names, fmt.Sprintf("@%dx%d", step.DurationValue, step.TargetValue))
+			continue
+		}
+		names = append(names, step.WktStepName)
+	}
+	return names
+}
+
+func checkSteps(t *testing.T, w Workout, want []string) {
+	t.Helper()
+	got := stepNames(w)
+	if len(got) != len(want) {
+		t.Fatalf("Wanted steps %v, got %v", want, got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("Wanted steps %v, got %v", want, got)
+		}
+		if int(w.Steps[i].MessageIndex) != i {
+			t.Errorf("Step %d has MessageIndex %d", i, w.Steps[i].MessageIndex)
+		}
+	}
+}
+
+func TestInsertStep(t *testing.T) {
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	checkSteps(t, w, []string{"w10", "45sec", "r75", "@1x6", "@1x4", "cd10"})
+
+	step := newWorkoutStep()
+	step.WktStepName = "drill"
+	step.DurationType = "Time"
+	step.DurationValue = 60000
+
+	err = w.InsertStep(1, step)
+	if err != nil {
+		t.Fatalf("InsertStep returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"w10", "drill", "45sec", "r75", "@2x6", "@2x4", "cd10"})
+
+	err = w.InsertStep(4, step)
+	if err != nil {
+		t.Fatalf("InsertStep returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"w10", "drill", "45sec", "r75", "drill", "@2x6", "@2x4", "cd10"})
+
+	err = w.InsertStep(6, step)
+	if err != nil {
+		t.Fatalf("InsertStep returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"w10", "drill", "45sec", "r75", "drill", "@2x6", "drill", "@2x4", "cd10"})
+
+	err = w.InsertStep(20, step)
+	if err == nil {
+		t.Errorf("Should have thrown an error")
+	}
+}
+
+func TestRemoveStep(t *testing.T) {
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	err = w.RemoveStep(1)
+	if err != nil {
+		t.Fatalf("RemoveStep returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"w10", "r75", "@1x6", "@1x4", "cd10"})
+
+	err = w.RemoveStep(1)
+	if err == nil {
+		t.Errorf("Removing the last step of a repeat block should have thrown an error")
+	}
+	checkSteps(t, w, []string{"w10", "r75", "@1x6", "@1x4", "cd10"})
+
+	err = w.RemoveStep(0)
+	if err != nil {
+		t.Fatalf("RemoveStep returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"r75", "@0x6", "@0x4", "cd10"})
+}
+
+func TestMoveStep(t *testing.T) {
+	w, err := ReadFit("testdata/fitsdk/WorkoutRepeatSteps.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	checkSteps(t, w, []string{"_A_", "B1_", "B2_", "@1x3", "_C_"})
+
+	err = w.MoveStep(0, 2)
+	if err != nil {
+		t.Fatalf("MoveStep returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"B1_", "B2_", "_A_", "@0x3", "_C_"})
+
+	err = w.MoveStep(4, 0)
+	if err != nil {
+		t.Fatalf("MoveStep returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"_C_", "B1_", "B2_", "_A_", "@1x3"})
+
+	err = w.MoveStep(4, 0)
+	if err == nil {
+		t.Errorf("Moving a repeat step should have thrown an error")
+	}
+}
+
+func TestWrapInRepeat(t *testing.T) {
+	w, err := ReadFit("testdata/fitsdk/WorkoutIndividualSteps.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	err = w.WrapInRepeat(1, 2, 3)
+	if err != nil {
+		t.Fatalf("WrapInRepeat returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"_A_", "B1_", "B2_", "@1x3", "_C_"})
+
+	err = w.WrapInRepeat(1, 3, 2)
+	if err != nil {
+		t.Fatalf("WrapInRepeat returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"_A_", "B1_", "B2_", "@1x3", "@1x2", "_C_"})
+
+	err = w.WrapInRepeat(2, 5, 2)
+	if err == nil {
+		t.Errorf("Overlapping repeat blocks should have thrown an error")
+	}
+	err = w.WrapInRepeat(2, 2, 4)
+	if err != nil {
+		t.Fatalf("WrapInRepeat returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"_A_", "B1_", "B2_", "@2x4", "@1x3", "@1x2", "_C_"})
+
+	err = w.Unwrap(4)
+	if err != nil {
+		t.Fatalf("Unwrap returned an error: %v", err)
+	}
+	checkSteps(t, w, []string{"_A_", "B1_", "B2_", "@2x4", "@1x2", "_C_"})
+
+	err = w.Unwrap(0)
+	if err == nil {
+		t.Errorf("Unwrapping a normal step should have thrown an error")
+	}
+}
@@ -0,0 +1,75 @@
+package goworkouts
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tormoder/fit"
+)
+
+// Errors that callers can check with errors.Is. The errors returned by the
+// package wrap them with more detail, like the file name or step.
+var (
+	// ErrStepNotFound is returned for a step position or MessageIndex that
+	// is not in the workout
+	ErrStepNotFound = errors.New("Step not found")
+	// ErrFileExists is returned when a file would be overwritten while
+	// overwrite was set to false
+	ErrFileExists = errors.New("File exists and overwrite was set to false")
+	// ErrInvalidRepeat is returned for repeat steps that do not form
+	// valid, nested blocks
+	ErrInvalidRepeat = errors.New("Invalid repeat block")
+	// ErrInvalidValue is returned for an imported value that is negative or
+	// does not fit in the field it is read into
+	ErrInvalidValue = errors.New("Invalid value")
+	// ErrInvalidFIT is returned for data that cannot be decoded as a FIT
+	// file. The error of the fit package, if any, is wrapped as well.
+	ErrInvalidFIT = errors.New("Invalid FIT file")
+	// ErrUnknownFormat is returned for a format that is not registered or
+	// data that no registered format recognizes
+	ErrUnknownFormat = errors.New("Unknown format")
+	// ErrNotSupported is returned by a Format that can only decode or only
+	// encode workouts, or that cannot express a workout
+	ErrNotSupported = errors.New("Not supported by the format")
+)
+
+// ErrNotWorkoutFile is returned when a FIT file of another type than workout
+// is read as a workout. Use errors.As to get the type of the file.
+// errors.Is matches any ErrNotWorkoutFile, whatever its type.
+type ErrNotWorkoutFile struct {
+	Got fit.FileType
+}
+
+func (e ErrNotWorkoutFile) Error() string {
+	return fmt.Sprintf("We only accept fit files of type Workout, got %v", e.Got)
+}
+
+// Is makes errors.Is(err, ErrNotWorkoutFile{}) true for every file type
+func (e ErrNotWorkoutFile) Is(target error) bool {
+	_, ok := target.(ErrNotWorkoutFile)
+	return ok
+}
+
+// StepError is an error in the step at position Index of a workout. Field
+// is the name of the WorkoutStep field at fault, if there is one.
+type StepError struct {
+	Index int
+	Field string
+	Err   error
+}
+
+func (e *StepError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("Step %d (%v): %v", e.Index, e.Field, e.Err)
+	}
+	return fmt.Sprintf("Step %d: %v", e.Index, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// stepError returns a StepError with a new error message
+func stepError(idx int, field string, format string, a ...interface{}) error {
+	return &StepError{Index: idx, Field: field, Err: fmt.Errorf(format, a...)}
+}
@@ -0,0 +1,131 @@
+package goworkouts
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/tormoder/fit"
+)
+
+func TestErrNotWorkoutFile(t *testing.T) {
+	_, err := ReadFit("testdata/fitsdk/Settings.fit")
+	if !errors.Is(err, ErrNotWorkoutFile{}) {
+		t.Fatalf("Got %v, wanted ErrNotWorkoutFile", err)
+	}
+	var notWorkout ErrNotWorkoutFile
+	if !errors.As(err, &notWorkout) {
+		t.Fatalf("errors.As did not find ErrNotWorkoutFile")
+	}
+	if notWorkout.Got != fit.FileTypeSettings {
+		t.Errorf("Got file type %v, wanted %v", notWorkout.Got, fit.FileTypeSettings)
+	}
+}
+
+func TestErrInvalidFIT(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/repeats.fit")
+	if err != nil {
+		t.Fatalf("Could not read testdata/repeats.fit")
+	}
+	data[len(data)-1]++
+	_, err = DecodeWorkout(bytes.NewReader(data))
+	if !errors.Is(err, ErrInvalidFIT) {
+		t.Fatalf("Got %v, wanted ErrInvalidFIT", err)
+	}
+	var integrity fit.IntegrityError
+	if !errors.As(err, &integrity) {
+		t.Errorf("The error of the fit package is not wrapped: %v", err)
+	}
+
+	_, err = ReadFit("testdata/missing.fit")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Got %v, wanted fs.ErrNotExist", err)
+	}
+}
+
+func TestErrFileExists(t *testing.T) {
+	w, err := ReadFit("testdata/repeats.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	f, err := w.ToFIT()
+	if err != nil {
+		t.Fatalf("ToFIT returned an error")
+	}
+	path := filepath.Join(t.TempDir(), "new.fit")
+	_, err = WriteFit(path, f, false)
+	if err != nil {
+		t.Fatalf("WriteFit returned an error: %v", err)
+	}
+	_, err = WriteFit(path, f, false)
+	if !errors.Is(err, ErrFileExists) {
+		t.Errorf("Got %v, wanted ErrFileExists", err)
+	}
+
+	plan := TrainingPlan{Name: "Plan", TrainingDays: []TrainingDay{{Order: 1, Workouts: []Workout{w}}}}
+	dir := t.TempDir()
+	err = plan.ExportFIT(dir, false)
+	if err != nil {
+		t.Fatalf("ExportFIT returned an error: %v", err)
+	}
+	err = plan.ExportFIT(dir, false)
+	if !errors.Is(err, ErrFileExists) {
+		t.Errorf("Got %v, wanted ErrFileExists", err)
+	}
+}
+
+func TestStepError(t *testing.T) {
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+
+	_, err = GetStepByIndex(w, 100)
+	if !errors.Is(err, ErrStepNotFound) {
+		t.Errorf("Got %v, wanted ErrStepNotFound", err)
+	}
+
+	err = w.RemoveStep(len(w.Steps))
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Got %v, wanted a StepError", err)
+	}
+	if stepErr.Index != len(w.Steps) || !errors.Is(err, ErrStepNotFound) {
+		t.Errorf("Wrong StepError %v", err)
+	}
+
+	err = w.Unwrap(0)
+	if !errors.As(err, &stepErr) || stepErr.Index != 0 || stepErr.Field != "DurationType" {
+		t.Errorf("Wrong StepError %v", err)
+	}
+
+	broken := w
+	broken.Steps = append([]WorkoutStep{}, w.Steps...)
+	for i, step := range broken.Steps {
+		if isRepeat(step) {
+			broken.Steps[i].DurationValue = 100
+			err = broken.Renumber()
+			if !errors.As(err, &stepErr) || stepErr.Index != i || stepErr.Field != "DurationValue" {
+				t.Errorf("Wrong StepError %v", err)
+			}
+			if !errors.Is(err, ErrStepNotFound) {
+				t.Errorf("Got %v, wanted ErrStepNotFound", err)
+			}
+			break
+		}
+	}
+}
+
+func TestStepErrorMessage(t *testing.T) {
+	err := &StepError{Index: 3, Field: "DurationValue", Err: ErrStepNotFound}
+	if err.Error() != "Step 3 (DurationValue): Step not found" {
+		t.Errorf("Wrong message %q", err.Error())
+	}
+	err = &StepError{Index: 3, Err: ErrStepNotFound}
+	if err.Error() != "Step 3: Step not found" {
+		t.Errorf("Wrong message %q", err.Error())
+	}
+}
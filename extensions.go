@@ -0,0 +1,256 @@
+package goworkouts
+
+import (
+	"fmt"
+
+	"github.com/tormoder/fit"
+)
+
+// FITField is the raw value of a FIT field that goworkouts does not model.
+// Multi-byte values are stored in little endian byte order.
+type FITField struct {
+	Num      byte   `json:"num" yaml:"num"`
+	BaseType byte   `json:"baseType" yaml:"baseType"`
+	Value    []byte `json:"value" yaml:"value"`
+}
+
+// FITDeveloperField is the raw value of a developer field. It is described
+// by the field description message with the same developer data index and
+// field number.
+type FITDeveloperField struct {
+	Num                byte   `json:"num" yaml:"num"`
+	DeveloperDataIndex byte   `json:"developerDataIndex" yaml:"developerDataIndex"`
+	Value              []byte `json:"value" yaml:"value"`
+}
+
+// FITMessage is a raw FIT message, like a developer data ID or field
+// description message
+type FITMessage struct {
+	MesgNum         uint16              `json:"mesgNum" yaml:"mesgNum"`
+	Fields          []FITField          `json:"fields,omitempty" yaml:"fields,omitempty"`
+	DeveloperFields []FITDeveloperField `json:"developerFields,omitempty" yaml:"developerFields,omitempty"`
+}
+
+// FITExtensions holds what DecodeWorkout finds in a FIT file that does not
+// fit in a Workout or WorkoutStep: unknown fields, developer fields and, for
+// the workout, the other messages of the file. MarshalFIT writes them back
+// unchanged.
+type FITExtensions struct {
+	Fields          []FITField          `json:"fields,omitempty" yaml:"fields,omitempty"`
+	DeveloperFields []FITDeveloperField `json:"developerFields,omitempty" yaml:"developerFields,omitempty"`
+	Messages        []FITMessage        `json:"messages,omitempty" yaml:"messages,omitempty"`
+}
+
+// empty returns true if there is nothing to keep
+func (e *FITExtensions) empty() bool {
+	return e == nil || (len(e.Fields) == 0 && len(e.DeveloperFields) == 0 && len(e.Messages) == 0)
+}
+
+// The fields of the workout and workout step messages that are read into
+// Workout and WorkoutStep. Any other field is kept as an extension.
+var (
+	modeledWorkoutFields = map[byte]bool{4: true, 5: true, 6: true, 8: true, 11: true, 14: true, 15: true, wktDescriptionField: true, 254: true}
+	modeledStepFields    = map[byte]bool{0: true, 1: true, 2: true, 3: true, 4: true, 5: true, 6: true, 7: true, 8: true, 254: true}
+	// messages that are written from the Workout
+	modeledMessages = map[uint16]bool{
+		uint16(fit.MesgNumFileId):      true,
+		uint16(fit.MesgNumFileCreator): true,
+		uint16(fit.MesgNumWorkout):     true,
+		uint16(fit.MesgNumWorkoutStep): true,
+	}
+)
+
+// baseTypeSizes are the sizes of the FIT base types, by base type number
+var baseTypeSizes = []int{1, 1, 1, 2, 2, 4, 4, 1, 4, 8, 1, 2, 4, 1, 8, 8, 8}
+
+// littleEndian returns a copy of a field value in little endian byte order
+func littleEndian(value []byte, baseType byte, bigEndian bool) []byte {
+	out := append([]byte{}, value...)
+	num := int(baseType & 0x1F)
+	if !bigEndian || baseType&0x80 == 0 || num >= len(baseTypeSizes) {
+		return out
+	}
+	size := baseTypeSizes[num]
+	for i := 0; i+size <= len(out); i += size {
+		for a, b := i, i+size-1; a < b; a, b = a+1, b-1 {
+			out[a], out[b] = out[b], out[a]
+		}
+	}
+	return out
+}
+
+// developerBaseTypes collects the base types of developer fields from the
+// field description messages, by developer data index and field number
+type developerBaseTypes map[[2]byte]byte
+
+// add reads a field description message
+func (d developerBaseTypes) add(r rawRecord) {
+	index, ok1 := r.field(0)
+	num, ok2 := r.field(1)
+	baseType, ok3 := r.field(2)
+	if ok1 && ok2 && ok3 && len(index) == 1 && len(num) == 1 && len(baseType) == 1 {
+		d[[2]byte{index[0], num[0]}] = baseType[0]
+	}
+}
+
+// recordExtensions returns the fields of a record that are not in modeled
+// and its developer fields
+func recordExtensions(r rawRecord, modeled map[byte]bool, dev developerBaseTypes) (FITExtensions, bool) {
+	var ext FITExtensions
+	pos := 0
+	for _, f := range r.Def.Fields {
+		value := r.Data[pos : pos+int(f.Size)]
+		pos += int(f.Size)
+		if !modeled[f.Num] {
+			ext.Fields = append(ext.Fields, FITField{
+				Num:      f.Num,
+				BaseType: f.BaseType,
+				Value:    littleEndian(value, f.BaseType, r.Def.BigEndian),
+			})
+		}
+	}
+	for _, f := range r.Def.DevFields {
+		value := r.Data[pos : pos+int(f.Size)]
+		pos += int(f.Size)
+		ext.DeveloperFields = append(ext.DeveloperFields, FITDeveloperField{
+			Num:                f.Num,
+			DeveloperDataIndex: f.BaseType,
+			Value:              littleEndian(value, dev[[2]byte{f.BaseType, f.Num}], r.Def.BigEndian),
+		})
+	}
+	return ext, len(ext.Fields) > 0 || len(ext.DeveloperFields) > 0
+}
+
+// readExtensions returns the extensions of the workout and of every workout
+// step of a FIT file
+func readExtensions(f *rawFIT) (*FITExtensions, []*FITExtensions) {
+	workout := &FITExtensions{}
+	var steps []*FITExtensions
+	dev := developerBaseTypes{}
+	for _, r := range f.Records {
+		switch {
+		case r.Def.Global == uint16(fit.MesgNumWorkout):
+			if ext, ok := recordExtensions(r, modeledWorkoutFields, dev); ok {
+				workout.Fields = ext.Fields
+				workout.DeveloperFields = ext.DeveloperFields
+			}
+		case r.Def.Global == uint16(fit.MesgNumWorkoutStep):
+			var step *FITExtensions
+			if ext, ok := recordExtensions(r, modeledStepFields, dev); ok {
+				step = &ext
+			}
+			steps = append(steps, step)
+		case !modeledMessages[r.Def.Global]:
+			if r.Def.Global == uint16(fit.MesgNumFieldDescription) {
+				dev.add(r)
+			}
+			ext, _ := recordExtensions(r, map[byte]bool{}, dev)
+			workout.Messages = append(workout.Messages, FITMessage{
+				MesgNum:         r.Def.Global,
+				Fields:          ext.Fields,
+				DeveloperFields: ext.DeveloperFields,
+			})
+		}
+	}
+	if workout.empty() {
+		workout = nil
+	}
+	return workout, steps
+}
+
+// setExtensions adds the fields of ext to a record. It returns an error if a
+// value or the number of fields does not fit in a FIT definition.
+func (r *rawRecord) setExtensions(ext *FITExtensions) error {
+	for _, f := range ext.Fields {
+		err := r.setField(f.Num, f.BaseType, f.Value)
+		if err != nil {
+			return err
+		}
+	}
+	if len(ext.DeveloperFields) == 0 {
+		return nil
+	}
+	if len(r.Def.DevFields)+len(ext.DeveloperFields) > rawMaxFields {
+		return fmt.Errorf("%w: more than %d developer fields in message %d", ErrInvalidFIT, rawMaxFields, r.Def.Global)
+	}
+	def := *r.Def
+	def.DevFields = append([]rawField{}, r.Def.DevFields...)
+	data := append([]byte{}, r.Data...)
+	for _, f := range ext.DeveloperFields {
+		if len(f.Value) > rawMaxSize {
+			return fmt.Errorf("%w: developer field %d has %d bytes, at most %d fit", ErrInvalidFIT, f.Num, len(f.Value), rawMaxSize)
+		}
+		def.DevFields = append(def.DevFields, rawField{Num: f.Num, Size: byte(len(f.Value)), BaseType: f.DeveloperDataIndex})
+		data = append(data, f.Value...)
+	}
+	r.Def = &def
+	r.Data = data
+	return nil
+}
+
+// messageRecord returns the record of a raw message
+func messageRecord(m FITMessage) (rawRecord, error) {
+	r := rawRecord{Def: &rawDef{Global: m.MesgNum}}
+	err := r.setExtensions(&FITExtensions{Fields: m.Fields, DeveloperFields: m.DeveloperFields})
+	return r, err
+}
+
+// writeExtensions adds the extensions of the workout and its steps to an
+// encoded workout file. The extra messages go before the workout message, as
+// the developer data ID and field description messages must come before the
+// fields they describe.
+func (w *Workout) writeExtensions(f *rawFIT) error {
+	var records []rawRecord
+	step := 0
+	for _, r := range f.Records {
+		switch r.Def.Global {
+		case uint16(fit.MesgNumWorkout):
+			if w.Extensions != nil {
+				for _, m := range w.Extensions.Messages {
+					record, err := messageRecord(m)
+					if err != nil {
+						return err
+					}
+					records = append(records, record)
+				}
+				err := r.setExtensions(w.Extensions)
+				if err != nil {
+					return err
+				}
+			}
+		case uint16(fit.MesgNumWorkoutStep):
+			if step < len(w.Steps) && !w.Steps[step].Extensions.empty() {
+				err := r.setExtensions(w.Steps[step].Extensions)
+				if err != nil {
+					return fmt.Errorf("Step %d: %w", step, err)
+				}
+			}
+			step++
+		}
+		records = append(records, r)
+	}
+	f.Records = records
+	return nil
+}
+
+// equalDefs returns true if two definitions are the same
+func equalDefs(a *rawDef, b *rawDef) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.BigEndian != b.BigEndian || a.Global != b.Global ||
+		len(a.Fields) != len(b.Fields) || len(a.DevFields) != len(b.DevFields) {
+		return false
+	}
+	for i := range a.Fields {
+		if a.Fields[i] != b.Fields[i] {
+			return false
+		}
+	}
+	for i := range a.DevFields {
+		if a.DevFields[i] != b.DevFields[i] {
+			return false
+		}
+	}
+	return true
+}
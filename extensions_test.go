@@ -0,0 +1,188 @@
+package goworkouts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tormoder/fit"
+)
+
+// developerWorkout returns a workout with the developer data of
+// DeveloperData.fit
+func developerWorkout(t *testing.T) Workout {
+	data, err := ioutil.ReadFile("testdata/fitsdk/DeveloperData.fit")
+	if err != nil {
+		t.Fatalf("Could not read file")
+	}
+	raw, err := parseRawFIT(data)
+	if err != nil {
+		t.Fatalf("parseRawFIT returned an error: %v", err)
+	}
+	extensions, _ := readExtensions(raw)
+	if extensions == nil {
+		t.Fatalf("No extensions found")
+	}
+	var messages []FITMessage
+	var developerFields []FITDeveloperField
+	for _, m := range extensions.Messages {
+		switch m.MesgNum {
+		case uint16(fit.MesgNumDeveloperDataId), uint16(fit.MesgNumFieldDescription):
+			messages = append(messages, m)
+		case uint16(fit.MesgNumRecord):
+			developerFields = m.DeveloperFields
+		}
+	}
+	if len(messages) != 2 || len(developerFields) != 1 {
+		t.Fatalf("Wrong developer data %+v", extensions)
+	}
+
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	w.Extensions = &FITExtensions{
+		Messages: messages,
+		Fields:   []FITField{{Num: 20, BaseType: 0x84, Value: []byte{1, 2}}},
+	}
+	w.Steps[1].Extensions = &FITExtensions{
+		Fields:          []FITField{{Num: 9, BaseType: 0x00, Value: []byte{3}}},
+		DeveloperFields: developerFields,
+	}
+	return w
+}
+
+func TestFITExtensionsRoundTrip(t *testing.T) {
+	w := developerWorkout(t)
+	data, err := w.MarshalFIT(FITOptions{Reproducible: true})
+	if err != nil {
+		t.Fatalf("MarshalFIT returned an error: %v", err)
+	}
+	_, err = fit.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Could not decode file with developer data: %v", err)
+	}
+
+	back, err := DecodeWorkout(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeWorkout returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(back.Extensions, w.Extensions) {
+		t.Errorf("Workout extensions changed:\n%+v\n%+v", back.Extensions, w.Extensions)
+	}
+	for i := range w.Steps {
+		if !reflect.DeepEqual(back.Steps[i].Extensions, w.Steps[i].Extensions) {
+			t.Errorf("Extensions of step %d changed: %+v", i, back.Steps[i].Extensions)
+		}
+	}
+	again, err := back.MarshalFIT(FITOptions{Reproducible: true})
+	if err != nil {
+		t.Fatalf("MarshalFIT returned an error: %v", err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("ReadFit -> MarshalFIT is not lossless")
+	}
+}
+
+// TestFITExtensionsWriteWorkout writes the developer data of DeveloperData.fit
+// with WriteWorkout and reads it back with ReadFit
+func TestFITExtensionsWriteWorkout(t *testing.T) {
+	w := developerWorkout(t)
+	path := filepath.Join(t.TempDir(), "developer.fit")
+	_, err := WriteWorkout(path, &w, WriteOptions{})
+	if err != nil {
+		t.Fatalf("WriteWorkout returned an error: %v", err)
+	}
+	back, err := ReadFit(path)
+	if err != nil {
+		t.Fatalf("ReadFit returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(back.Extensions, w.Extensions) {
+		t.Errorf("Workout extensions changed:\n%+v\n%+v", back.Extensions, w.Extensions)
+	}
+	for i := range w.Steps {
+		if !reflect.DeepEqual(back.Steps[i].Extensions, w.Steps[i].Extensions) {
+			t.Errorf("Extensions of step %d changed: %+v", i, back.Steps[i].Extensions)
+		}
+	}
+
+	again := filepath.Join(t.TempDir(), "again.fit")
+	_, err = WriteWorkout(again, &back, WriteOptions{})
+	if err != nil {
+		t.Fatalf("WriteWorkout returned an error: %v", err)
+	}
+	reread, err := ReadFit(again)
+	if err != nil || !reflect.DeepEqual(reread.Extensions, w.Extensions) || !reflect.DeepEqual(reread.Steps, back.Steps) {
+		t.Errorf("ReadFit -> WriteWorkout is not lossless: %v", err)
+	}
+}
+
+func TestFITExtensionsTooLarge(t *testing.T) {
+	long := bytes.Repeat([]byte{1}, 256)
+	tests := []*FITExtensions{
+		{Fields: []FITField{{Num: 20, BaseType: 0x0D, Value: long}}},
+		{DeveloperFields: []FITDeveloperField{{Num: 0, DeveloperDataIndex: 0, Value: long}}},
+		{Messages: []FITMessage{{MesgNum: 0xFF00, Fields: []FITField{{Num: 1, BaseType: 0x0D, Value: long}}}}},
+	}
+	for i, ext := range tests {
+		w := Workout{Name: "Long", Sport: "rowing", Steps: []WorkoutStep{{DurationType: "Open", TargetType: "Open", Intensity: "Active"}}}
+		w.Extensions = ext
+		_, err := w.MarshalFIT(FITOptions{})
+		if !errors.Is(err, ErrInvalidFIT) {
+			t.Errorf("Test %d: expected ErrInvalidFIT, got %v", i, err)
+		}
+		w.Extensions = nil
+		w.Steps[0].Extensions = ext
+		_, err = w.MarshalFIT(FITOptions{})
+		if i < 2 && !errors.Is(err, ErrInvalidFIT) {
+			t.Errorf("Test %d: expected ErrInvalidFIT for a step, got %v", i, err)
+		}
+	}
+
+	w := Workout{Name: "Max", Sport: "rowing", Extensions: &FITExtensions{Fields: []FITField{{Num: 20, BaseType: 0x0D, Value: long[:255]}}}}
+	_, err := w.MarshalFIT(FITOptions{})
+	if err != nil {
+		t.Errorf("A field of 255 bytes returned an error: %v", err)
+	}
+}
+
+func TestFITExtensionsSerialize(t *testing.T) {
+	w := developerWorkout(t)
+	data, err := w.ToJSON()
+	if err != nil {
+		t.Fatalf("Could not convert to JSON")
+	}
+	var fromJSON Workout
+	err = json.Unmarshal(data, &fromJSON)
+	if err != nil {
+		t.Fatalf("Could not read JSON: %v", err)
+	}
+	data, err = w.ToYAML()
+	if err != nil {
+		t.Fatalf("Could not convert to YAML")
+	}
+	fromYAML, err := FromYAML(string(data))
+	if err != nil {
+		t.Fatalf("Could not read YAML: %v", err)
+	}
+	for _, back := range []Workout{fromJSON, fromYAML} {
+		if !reflect.DeepEqual(back.Extensions, w.Extensions) || !reflect.DeepEqual(back.Steps[1].Extensions, w.Steps[1].Extensions) {
+			t.Errorf("Extensions changed: %+v", back.Extensions)
+		}
+	}
+}
+
+func TestLittleEndian(t *testing.T) {
+	got := littleEndian([]byte{1, 2, 3, 4, 5, 6, 7, 8}, 0x84, true)
+	if !bytes.Equal(got, []byte{2, 1, 4, 3, 6, 5, 8, 7}) {
+		t.Errorf("Wrong uint16 array %v", got)
+	}
+	got = littleEndian([]byte{1, 2, 3, 4}, 0x07, true)
+	if !bytes.Equal(got, []byte{1, 2, 3, 4}) {
+		t.Errorf("String changed %v", got)
+	}
+}
@@ -0,0 +1,212 @@
+package goworkouts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// LossKind says how an export changed a value
+type LossKind string
+
+const (
+	// LossDropped means the value is not written at all
+	LossDropped LossKind = "dropped"
+	// LossApproximated means the value is written rounded or truncated
+	LossApproximated LossKind = "approximated"
+	// LossRewritten means another value is written in its place
+	LossRewritten LossKind = "rewritten"
+)
+
+// Loss is a value of the workout that an export does not keep exactly
+type Loss struct {
+	Step   int      `json:"step" yaml:"step"` // position of the step, -1 for the workout
+	Field  string   `json:"field" yaml:"field"`
+	Kind   LossKind `json:"kind" yaml:"kind"`
+	Reason string   `json:"reason" yaml:"reason"`
+}
+
+// FidelityReport lists what an export to a format does not keep exactly
+type FidelityReport struct {
+	Format string `json:"format" yaml:"format"`
+	Losses []Loss `json:"losses" yaml:"losses"`
+}
+
+// Lossless returns true if the export keeps the workout exactly
+func (r *FidelityReport) Lossless() bool {
+	return len(r.Losses) == 0
+}
+
+// add adds a loss to the report
+func (r *FidelityReport) add(step int, field string, kind LossKind, format string, a ...interface{}) {
+	r.Losses = append(r.Losses, Loss{Step: step, Field: field, Kind: kind, Reason: fmt.Sprintf(format, a...)})
+}
+
+// FidelityReporter is implemented by formats that can tell what an export of
+// a workout loses
+type FidelityReporter interface {
+	Fidelity(w *Workout) FidelityReport
+}
+
+// Fidelity returns what an export of w to format loses. The format is a name
+// or extension as for LookupFormat.
+func Fidelity(w *Workout, format string) (FidelityReport, error) {
+	f, err := LookupFormat(format)
+	if err != nil {
+		return FidelityReport{}, err
+	}
+	reporter, ok := f.(FidelityReporter)
+	if !ok {
+		return FidelityReport{}, fmt.Errorf("%w: format %v has no fidelity report", ErrNotSupported, f.Name())
+	}
+	return reporter.Fidelity(w), nil
+}
+
+// The FIT profile sizes of the string fields, including the terminating zero
+const (
+	fitNameSize  = 16
+	fitNotesSize = 50
+)
+
+// addTruncated reports a string that does not fit in a FIT field
+func (r *FidelityReport) addTruncated(step int, field string, s string, size int) {
+	if len(s) >= size {
+		r.add(step, field, LossApproximated, "The FIT profile allows at most %d bytes, devices may cut it off", size-1)
+	}
+}
+
+// fitFidelity reports what MarshalFIT does not keep
+func (w *Workout) fitFidelity() FidelityReport {
+	report := FidelityReport{Format: "fit"}
+	report.addTruncated(-1, "Name", w.Name, fitNameSize)
+	if _, ok := sportMapping[w.Sport]; !ok && w.Sport != "" {
+		report.add(-1, "Sport", LossRewritten, "Unknown sport %q is written as generic", w.Sport)
+	}
+	if _, ok := subSportMapping[w.SubSport]; !ok && w.SubSport != "" {
+		report.add(-1, "SubSport", LossDropped, "Unknown sub sport %q", w.SubSport)
+	}
+	if len(w.Description) > 254 {
+		report.add(-1, "Description", LossApproximated, "FIT keeps at most 254 bytes")
+	}
+	if w.PoolLength > 0 {
+		if _, ok := poolLengthUnits[w.PoolLengthUnit]; !ok {
+			report.add(-1, "PoolLengthUnit", LossRewritten, "Unknown unit %q is written as Metric", w.PoolLengthUnit)
+		}
+		cm := math.Round(w.PoolLength * 100)
+		if cm > math.MaxUint16-1 || cm != w.PoolLength*100 {
+			report.add(-1, "PoolLength", LossApproximated, "FIT keeps the pool length in whole cm")
+		}
+	}
+	for i, step := range w.Steps {
+		report.addTruncated(i, "WktStepName", step.WktStepName, fitNameSize)
+		report.addTruncated(i, "Notes", step.Notes, fitNotesSize)
+		if _, ok := durationTypes[step.DurationType]; !ok {
+			report.add(i, "DurationType", LossRewritten, "Unknown duration type %q", step.DurationType)
+		}
+		if _, ok := targetTypes[step.TargetType]; !ok {
+			report.add(i, "TargetType", LossRewritten, "Unknown target type %q", step.TargetType)
+		}
+		if _, ok := intensityTypes[step.Intensity]; !ok {
+			report.add(i, "Intensity", LossRewritten, "Unknown intensity %q", step.Intensity)
+		}
+	}
+	return report
+}
+
+// MarshalFITWithReport is MarshalFIT, with a report of what the FIT file
+// does not keep
+func (w *Workout) MarshalFITWithReport(opts FITOptions) ([]byte, FidelityReport, error) {
+	data, err := w.MarshalFIT(opts)
+	return data, w.fitFidelity(), err
+}
+
+// intervalsFidelity reports what ToIntervals does not keep
+func (w *Workout) intervalsFidelity() FidelityReport {
+	report := FidelityReport{Format: "intervals"}
+	workoutFields := []struct {
+		name string
+		set  bool
+	}{
+		{"Name", w.Name != ""},
+		{"Sport", w.Sport != ""},
+		{"Description", w.Description != ""},
+		{"SubSport", w.SubSport != ""},
+		{"PoolLength", w.PoolLength != 0},
+		{"Extensions", !w.Extensions.empty()},
+	}
+	for _, field := range workoutFields {
+		if field.set {
+			report.add(-1, field.name, LossDropped, "Intervals text only has the steps")
+		}
+	}
+	for i, step := range w.Steps {
+		if !step.Extensions.empty() {
+			report.add(i, "Extensions", LossDropped, "Intervals text has no FIT extensions")
+		}
+		if isRepeat(step) {
+			if step.DurationType != "RepeatUntilStepsCmplt" {
+				report.add(i, "DurationType", LossRewritten, "%v is written as a step without duration", step.DurationType)
+			}
+			continue
+		}
+		switch step.DurationType {
+		case "Time", "Distance":
+		default:
+			report.add(i, "DurationType", LossDropped, "Duration type %v has no intervals text", step.DurationType)
+		}
+
+		switch step.Intensity {
+		case "Warmup", "Cooldown", "Recovery", "Rest":
+			if step.TargetType != "" && step.TargetType != "Open" {
+				report.add(i, "TargetType", LossRewritten, "The target of a %v step is replaced by a zone", step.Intensity)
+			}
+			continue
+		}
+		switch step.TargetType {
+		case "", "Open", "Power", "PowerLap", "HeartRate", "HeartRateLap", "Cadence":
+		default:
+			report.add(i, "TargetType", LossDropped, "Target type %v has no intervals text", step.TargetType)
+		}
+	}
+	return report
+}
+
+// ToIntervalsWithReport is ToIntervals, with a report of what the text does
+// not keep
+func (w *Workout) ToIntervalsWithReport() (string, FidelityReport, error) {
+	text, err := w.ToIntervals()
+	return text, w.intervalsFidelity(), err
+}
+
+// ConvertWithReport is Convert, with the fidelity report of the target
+// format. The report is empty if the format has none.
+func ConvertWithReport(in io.Reader, out io.Writer, from, to string) (FidelityReport, error) {
+	target, err := LookupFormat(to)
+	if err != nil {
+		return FidelityReport{}, err
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return FidelityReport{}, err
+	}
+	var source Format
+	if from == "" {
+		source, err = DetectFormat(data, "")
+	} else {
+		source, err = LookupFormat(from)
+	}
+	if err != nil {
+		return FidelityReport{}, err
+	}
+	w, err := source.Decode(bytes.NewReader(data))
+	if err != nil {
+		return FidelityReport{}, err
+	}
+	report := FidelityReport{Format: target.Name()}
+	if reporter, ok := target.(FidelityReporter); ok {
+		report = reporter.Fidelity(&w)
+	}
+	return report, target.Encode(out, &w)
+}
@@ -0,0 +1,145 @@
+package goworkouts
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/tormoder/fit"
+)
+
+// hasLoss returns true if the report has a loss of kind for field of step
+func hasLoss(report FidelityReport, step int, field string, kind LossKind) bool {
+	for _, loss := range report.Losses {
+		if loss.Step == step && loss.Field == field && loss.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// fidelityWorkout returns a workout with a step of every kind of loss
+func fidelityWorkout() Workout {
+	steps := []WorkoutStep{
+		{DurationType: "Time", DurationValue: 600000, TargetType: "HeartRate", TargetValue: 2, Intensity: "Warmup"},
+		{DurationType: "Distance", DurationValue: 100000, TargetType: "Speed", CustomTargetValueLow: 4000, CustomTargetValueHigh: 4500, Intensity: "Active"},
+		{DurationType: "Calories", DurationValue: 100, TargetType: "Power", TargetValue: 3, Intensity: "Active"},
+		{DurationType: "RepeatUntilStepsCmplt", DurationValue: 1, TargetType: "Open", TargetValue: 3, Intensity: "Active"},
+		{DurationType: "Open", TargetType: "Cadence", TargetValue: 20, Intensity: "Rest"},
+	}
+	for i := range steps {
+		steps[i].MessageIndex = fit.MessageIndex(i)
+	}
+	return Workout{Name: "Fidelity", Sport: "rowing", Steps: steps}
+}
+
+func TestIntervalsFidelity(t *testing.T) {
+	w := fidelityWorkout()
+	text, report, err := w.ToIntervalsWithReport()
+	if err != nil {
+		t.Fatalf("ToIntervalsWithReport returned an error: %v", err)
+	}
+	want, _ := w.ToIntervals()
+	if text != want {
+		t.Errorf("ToIntervalsWithReport wrote %q, wanted %q", text, want)
+	}
+	if report.Format != "intervals" || report.Lossless() {
+		t.Fatalf("Wrong report %+v", report)
+	}
+	tests := []struct {
+		step  int
+		field string
+		kind  LossKind
+	}{
+		{-1, "Name", LossDropped},
+		{-1, "Sport", LossDropped},
+		{0, "TargetType", LossRewritten},
+		{1, "TargetType", LossDropped},
+		{2, "DurationType", LossDropped},
+		{4, "DurationType", LossDropped},
+		{4, "TargetType", LossRewritten},
+	}
+	for _, test := range tests {
+		if !hasLoss(report, test.step, test.field, test.kind) {
+			t.Errorf("Report does not have %v %v of step %d", test.kind, test.field, test.step)
+		}
+	}
+	if len(report.Losses) != len(tests) {
+		t.Errorf("Got %d losses, wanted %d: %+v", len(report.Losses), len(tests), report.Losses)
+	}
+}
+
+func TestFITFidelity(t *testing.T) {
+	w := fidelityWorkout()
+	_, report, err := w.MarshalFITWithReport(FITOptions{})
+	if err != nil {
+		t.Fatalf("MarshalFITWithReport returned an error: %v", err)
+	}
+	if !report.Lossless() {
+		t.Errorf("Expected a lossless FIT export, got %+v", report.Losses)
+	}
+
+	w.Name = strings.Repeat("x", 20)
+	w.Sport = "curling"
+	w.PoolLength = 25.005
+	w.Steps[0].Notes = strings.Repeat("n", 60)
+	w.Steps[1].Intensity = "Fast"
+	data, report, err := w.MarshalFITWithReport(FITOptions{})
+	if err != nil {
+		t.Fatalf("MarshalFITWithReport returned an error: %v", err)
+	}
+	tests := []struct {
+		step  int
+		field string
+		kind  LossKind
+	}{
+		{-1, "Name", LossApproximated},
+		{-1, "Sport", LossRewritten},
+		{-1, "PoolLength", LossApproximated},
+		{-1, "PoolLengthUnit", LossRewritten},
+		{0, "Notes", LossApproximated},
+		{1, "Intensity", LossRewritten},
+	}
+	for _, test := range tests {
+		if !hasLoss(report, test.step, test.field, test.kind) {
+			t.Errorf("Report does not have %v %v of step %d", test.kind, test.field, test.step)
+		}
+	}
+	if len(report.Losses) != len(tests) {
+		t.Errorf("Got %d losses, wanted %d: %+v", len(report.Losses), len(tests), report.Losses)
+	}
+
+	_, err = DecodeWorkout(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeWorkout returned an error: %v", err)
+	}
+}
+
+func TestFidelity(t *testing.T) {
+	w := fidelityWorkout()
+	for _, format := range []string{"json", "yaml"} {
+		report, err := Fidelity(&w, format)
+		if err != nil || !report.Lossless() {
+			t.Errorf("%v: got %+v, %v, wanted a lossless report", format, report, err)
+		}
+	}
+	report, err := Fidelity(&w, ".txt")
+	if err != nil || report.Format != "intervals" || report.Lossless() {
+		t.Errorf("Got %+v, %v for intervals", report, err)
+	}
+	_, err = Fidelity(&w, "upper")
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Got %v, wanted ErrNotSupported", err)
+	}
+
+	data, _ := w.ToJSON()
+	report, err = ConvertWithReport(bytes.NewReader(data), ioutil.Discard, "", "intervals")
+	if err != nil {
+		t.Fatalf("ConvertWithReport returned an error: %v", err)
+	}
+	if !hasLoss(report, 1, "TargetType", LossDropped) {
+		t.Errorf("ConvertWithReport did not report the speed target")
+	}
+}
@@ -0,0 +1,154 @@
+package goworkouts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tormoder/fit"
+)
+
+// FITOptions set the FileId, creator and protocol version of FIT files made
+// by ToFITWithOptions
+type FITOptions struct {
+	// Manufacturer defaults to fit.ManufacturerGarmin, which some devices
+	// need. Use fit.ManufacturerDevelopment to identify the file as made by
+	// your own software.
+	Manufacturer fit.Manufacturer
+	Product      uint16 // left out if 0
+	SerialNumber uint32 // left out if 0
+	// TimeCreated defaults to the current time. In reproducible mode it is
+	// left out of the file unless it is set.
+	TimeCreated time.Time
+	// SoftwareVersion and HardwareVersion go in the file creator message
+	// when set
+	SoftwareVersion uint16
+	HardwareVersion uint8
+	// Protocol is fit.V10 (default) or fit.V20
+	Protocol fit.ProtocolVersion
+	// Reproducible makes the same Workout always encode to the same bytes
+	Reproducible bool
+}
+
+// ToFITWithOptions exports to FIT with the file metadata set by opts. Encode
+// the file with EncodeFit to get the same bytes for the same messages. The
+// fit package cannot hold the description and FIT extensions of the workout,
+// use MarshalFIT to include them.
+func (w *Workout) ToFITWithOptions(opts FITOptions) (*fit.File, error) {
+	protocol := opts.Protocol
+	if protocol == 0 {
+		protocol = fit.V10
+	}
+	h := fit.NewHeader(protocol, true)
+
+	workoutmsg := w.workoutMsg()
+
+	WorkoutSteps := []*fit.WorkoutStepMsg{}
+
+	for _, step := range w.Steps {
+		newmsg := fit.NewWorkoutStepMsg()
+
+		newmsg.MessageIndex = step.MessageIndex
+		newmsg.WktStepName = step.WktStepName
+		newmsg.DurationType = durationTypes[step.DurationType]
+		newmsg.DurationValue = step.DurationValue
+		newmsg.Intensity = intensityTypes[step.Intensity]
+		newmsg.Notes = step.Notes
+		newmsg.TargetType = targetTypes[step.TargetType]
+		newmsg.TargetValue = step.TargetValue
+		newmsg.CustomTargetValueLow = step.CustomTargetValueLow
+		newmsg.CustomTargetValueHigh = step.CustomTargetValueHigh
+		WorkoutSteps = append(WorkoutSteps, newmsg)
+	}
+
+	workoutFile := fit.WorkoutFile{}
+	workoutFile.Workout = workoutmsg
+	workoutFile.WorkoutSteps = WorkoutSteps
+
+	newFile, err := fit.NewFile(fit.FileTypeWorkout, h)
+	if err != nil {
+		return newFile, err
+	}
+
+	newFile.FileId.Manufacturer = fit.ManufacturerGarmin
+	if opts.Manufacturer != 0 {
+		newFile.FileId.Manufacturer = opts.Manufacturer
+	}
+	if opts.Product != 0 {
+		newFile.FileId.Product = opts.Product
+	}
+	if opts.SerialNumber != 0 {
+		newFile.FileId.SerialNumber = opts.SerialNumber
+	}
+	switch {
+	case !opts.TimeCreated.IsZero():
+		newFile.FileId.TimeCreated = opts.TimeCreated
+	case !opts.Reproducible:
+		newFile.FileId.TimeCreated = time.Now()
+	}
+	if opts.SoftwareVersion != 0 || opts.HardwareVersion != 0 {
+		creator := fit.NewFileCreatorMsg()
+		if opts.SoftwareVersion != 0 {
+			creator.SoftwareVersion = opts.SoftwareVersion
+		}
+		if opts.HardwareVersion != 0 {
+			creator.HardwareVersion = opts.HardwareVersion
+		}
+		newFile.FileCreator = creator
+	}
+
+	newFileWorkoutFile, err := newFile.Workout()
+	if err != nil {
+		return newFile, err
+	}
+
+	*newFileWorkoutFile = workoutFile
+
+	return newFile, nil
+}
+
+// EncodeFit writes a FIT file to wr. The fields of the messages are always
+// written in the same order, so the same messages give the same bytes.
+func EncodeFit(wr io.Writer, f *fit.File) error {
+	var buf bytes.Buffer
+	err := fit.Encode(&buf, f, binary.LittleEndian)
+	if err != nil {
+		return fmt.Errorf("Could not encode FIT file: %w", err)
+	}
+	data, err := canonicalFIT(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(data)
+	return err
+}
+
+// MarshalFIT encodes the workout as a FIT file like EncodeFit, including the
+// description of the workout and the FIT extensions of the workout and its
+// steps
+func (w *Workout) MarshalFIT(opts FITOptions) ([]byte, error) {
+	f, err := w.ToFITWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = EncodeFit(&buf, f)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := parseRawFIT(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = setDescription(raw, w.Description)
+	if err != nil {
+		return nil, err
+	}
+	err = w.writeExtensions(raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw.bytes(), nil
+}
@@ -0,0 +1,119 @@
+package goworkouts
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/tormoder/fit"
+)
+
+func encodeWorkout(t *testing.T, w Workout, opts FITOptions) []byte {
+	f, err := w.ToFITWithOptions(opts)
+	if err != nil {
+		t.Fatalf("ToFITWithOptions returned an error: %v", err)
+	}
+	var buf bytes.Buffer
+	err = EncodeFit(&buf, f)
+	if err != nil {
+		t.Fatalf("EncodeFit returned an error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReproducibleFIT(t *testing.T) {
+	w, err := ReadFit("testdata/fitsdk/WorkoutCustomTargetValues.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	opts := FITOptions{Reproducible: true}
+	first := encodeWorkout(t, w, opts)
+	// the fit package writes the fields of the steps in random order
+	for i := 0; i < 20; i++ {
+		again := encodeWorkout(t, w, opts)
+		if !bytes.Equal(first, again) {
+			t.Fatalf("Encoding %d differs", i)
+		}
+	}
+
+	back, err := DecodeWorkout(bytes.NewReader(first))
+	if err != nil {
+		t.Fatalf("DecodeWorkout returned an error: %v", err)
+	}
+	if back.Name != w.Name || len(back.Steps) != len(w.Steps) || back.Steps[0] != w.Steps[0] {
+		t.Errorf("Workout changed after encoding")
+	}
+}
+
+func TestFITOptions(t *testing.T) {
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	opts := FITOptions{
+		Manufacturer:    fit.ManufacturerDevelopment,
+		Product:         42,
+		SerialNumber:    123456,
+		TimeCreated:     created,
+		SoftwareVersion: 310,
+		Protocol:        fit.V20,
+	}
+	data := encodeWorkout(t, w, opts)
+	f, err := fit.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Could not decode file: %v", err)
+	}
+	if f.Header.ProtocolVersion != fit.V20.Version() {
+		t.Errorf("Wanted protocol version 2.0, got %v", f.Header.ProtocolVersion)
+	}
+	id := f.FileId
+	if id.Manufacturer != fit.ManufacturerDevelopment || id.Product != 42 || id.SerialNumber != 123456 || !id.TimeCreated.Equal(created) {
+		t.Errorf("Wrong FileId %+v", id)
+	}
+	if f.FileCreator == nil || f.FileCreator.SoftwareVersion != 310 {
+		t.Errorf("Wrong file creator %+v", f.FileCreator)
+	}
+
+	f, err = w.ToFIT()
+	if err != nil {
+		t.Fatalf("ToFIT returned an error")
+	}
+	if f.FileId.Manufacturer != fit.ManufacturerGarmin || time.Since(f.FileId.TimeCreated) > time.Minute {
+		t.Errorf("Wrong default FileId %+v", f.FileId)
+	}
+}
+
+func TestCanonicalFIT(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/fitsdk/Activity.fit")
+	if err != nil {
+		t.Fatalf("Could not read file")
+	}
+	canonical, err := canonicalFIT(data)
+	if err != nil {
+		t.Fatalf("canonicalFIT returned an error: %v", err)
+	}
+	again, err := canonicalFIT(canonical)
+	if err != nil || !bytes.Equal(canonical, again) {
+		t.Errorf("canonicalFIT is not stable")
+	}
+	a, err := fit.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Could not decode file: %v", err)
+	}
+	b, err := fit.Decode(bytes.NewReader(canonical))
+	if err != nil {
+		t.Fatalf("Could not decode canonical file: %v", err)
+	}
+	aa, _ := a.Activity()
+	ba, _ := b.Activity()
+	if len(aa.Records) != len(ba.Records) || aa.Records[10].Timestamp != ba.Records[10].Timestamp || aa.Records[10].HeartRate != ba.Records[10].HeartRate {
+		t.Errorf("Records changed")
+	}
+
+	_, err = canonicalFIT(data[:20])
+	if err == nil {
+		t.Errorf("Should have thrown an error")
+	}
+}
@@ -0,0 +1,257 @@
+package goworkouts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Format is a file format for workouts. FIT, JSON, YAML, intervals text,
+// Garmin Connect JSON, TrainingPeaks JSON and Concept2 JSON and CSV are
+// registered by default. Other packages can add formats with RegisterFormat,
+// usually in an init function.
+type Format interface {
+	// Name is a short lower case name, like "fit"
+	Name() string
+	// Extensions are the file name extensions of the format, with the
+	// leading dot, like ".fit"
+	Extensions() []string
+	// Detect returns true if data looks like a file of the format. It is
+	// given the whole file.
+	Detect(data []byte) bool
+	// Decode reads a workout, or returns ErrNotSupported
+	Decode(r io.Reader) (Workout, error)
+	// Encode writes a workout, or returns ErrNotSupported
+	Encode(wr io.Writer, w *Workout) error
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []Format
+)
+
+// RegisterFormat adds a format to the registry. It panics if the name is
+// empty or already registered.
+func RegisterFormat(f Format) {
+	name := strings.ToLower(f.Name())
+	if name == "" {
+		panic("goworkouts: RegisterFormat with an empty name")
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for _, other := range formats {
+		if strings.ToLower(other.Name()) == name {
+			panic("goworkouts: RegisterFormat called twice for format " + name)
+		}
+	}
+	formats = append(formats, f)
+}
+
+// Formats returns the registered formats in order of registration
+func Formats() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return append([]Format{}, formats...)
+}
+
+// hasExtension returns true if ext is one of the extensions of f
+func hasExtension(f Format, ext string) bool {
+	for _, e := range f.Extensions() {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupFormat returns the format with the given name or extension, like
+// "fit" or ".fit"
+func LookupFormat(name string) (Format, error) {
+	for _, f := range Formats() {
+		if strings.EqualFold(f.Name(), name) || (strings.HasPrefix(name, ".") && hasExtension(f, name)) {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, name)
+}
+
+// DetectFormat returns the format of data. The formats of the extension of
+// filename are tried first, then all formats by their Detect method. If
+// nothing detects the data, the first format with the extension is
+// returned. The filename may be empty.
+func DetectFormat(data []byte, filename string) (Format, error) {
+	all := Formats()
+	ext := filepath.Ext(filename)
+	var byExtension Format
+	if ext != "" {
+		for _, f := range all {
+			if !hasExtension(f, ext) {
+				continue
+			}
+			if f.Detect(data) {
+				return f, nil
+			}
+			if byExtension == nil {
+				byExtension = f
+			}
+		}
+	}
+	for _, f := range all {
+		if f.Detect(data) {
+			return f, nil
+		}
+	}
+	if byExtension != nil {
+		return byExtension, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Convert reads a workout in format from and writes it in format to. The
+// formats are names or extensions as for LookupFormat. If from is empty, the
+// format is detected from the data.
+func Convert(in io.Reader, out io.Writer, from, to string) error {
+	_, err := ConvertWithReport(in, out, from, to)
+	return err
+}
+
+// isFIT returns true if data starts with a FIT file header
+func isFIT(data []byte) bool {
+	return len(data) >= 12 && (data[0] == 12 || data[0] == 14) && string(data[8:12]) == ".FIT"
+}
+
+type fitFormat struct{}
+
+func (fitFormat) Name() string                        { return "fit" }
+func (fitFormat) Extensions() []string                { return []string{".fit"} }
+func (fitFormat) Detect(data []byte) bool             { return isFIT(data) }
+func (fitFormat) Decode(r io.Reader) (Workout, error) { return DecodeWorkout(r) }
+func (fitFormat) Fidelity(w *Workout) FidelityReport  { return w.fitFidelity() }
+
+func (fitFormat) Encode(wr io.Writer, w *Workout) error {
+	data, err := w.MarshalFIT(FITOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(data)
+	return err
+}
+
+type jsonFormat struct{}
+
+func (jsonFormat) Name() string         { return "json" }
+func (jsonFormat) Extensions() []string { return []string{".json"} }
+
+// Fidelity is always lossless, JSON has every field of the workout
+func (jsonFormat) Fidelity(w *Workout) FidelityReport {
+	return FidelityReport{Format: "json"}
+}
+
+func (jsonFormat) Detect(data []byte) bool {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) != nil {
+		return false
+	}
+	_, ok := object["steps"]
+	return ok
+}
+
+func (jsonFormat) Decode(r io.Reader) (Workout, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Workout{}, err
+	}
+	return FromJSON(string(data))
+}
+
+func (jsonFormat) Encode(wr io.Writer, w *Workout) error {
+	data, err := w.ToJSON()
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(data)
+	return err
+}
+
+type yamlFormat struct{}
+
+func (yamlFormat) Name() string         { return "yaml" }
+func (yamlFormat) Extensions() []string { return []string{".yaml", ".yml"} }
+
+// Fidelity is always lossless, YAML has every field of the workout
+func (yamlFormat) Fidelity(w *Workout) FidelityReport {
+	return FidelityReport{Format: "yaml"}
+}
+
+// Detect does not accept JSON, which is also valid YAML
+func (yamlFormat) Detect(data []byte) bool {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return false
+	}
+	var object map[string]interface{}
+	if yaml.Unmarshal(data, &object) != nil {
+		return false
+	}
+	_, ok := object["steps"]
+	return ok
+}
+
+func (yamlFormat) Decode(r io.Reader) (Workout, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Workout{}, err
+	}
+	return FromYAML(string(data))
+}
+
+func (yamlFormat) Encode(wr io.Writer, w *Workout) error {
+	data, err := w.ToYAML()
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(data)
+	return err
+}
+
+// intervalsFormat is the intervals.icu workout text. There is no parser for
+// it, so it is never detected and can only be written.
+type intervalsFormat struct{}
+
+func (intervalsFormat) Name() string            { return "intervals" }
+func (intervalsFormat) Extensions() []string    { return []string{".txt"} }
+func (intervalsFormat) Detect(data []byte) bool { return false }
+
+func (intervalsFormat) Fidelity(w *Workout) FidelityReport {
+	return w.intervalsFidelity()
+}
+
+func (intervalsFormat) Decode(r io.Reader) (Workout, error) {
+	return Workout{}, fmt.Errorf("%w: cannot read intervals text", ErrNotSupported)
+}
+
+func (intervalsFormat) Encode(wr io.Writer, w *Workout) error {
+	text, err := w.ToIntervals()
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(wr, text)
+	return err
+}
+
+func init() {
+	RegisterFormat(fitFormat{})
+	RegisterFormat(jsonFormat{})
+	RegisterFormat(yamlFormat{})
+	RegisterFormat(intervalsFormat{})
+	RegisterFormat(garminConnectFormat{})
+	RegisterFormat(trainingPeaksFormat{})
+	RegisterFormat(concept2Format{})
+	RegisterFormat(concept2CSVFormat{})
+}
@@ -0,0 +1,151 @@
+package goworkouts
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// upperFormat is a test format that writes the workout name in capitals
+type upperFormat struct{}
+
+func (upperFormat) Name() string            { return "upper" }
+func (upperFormat) Extensions() []string    { return []string{".upper"} }
+func (upperFormat) Detect(data []byte) bool { return bytes.HasPrefix(data, []byte("UPPER ")) }
+
+func (upperFormat) Decode(r io.Reader) (Workout, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Workout{}, err
+	}
+	return Workout{Name: strings.TrimPrefix(string(data), "UPPER ")}, nil
+}
+
+func (upperFormat) Encode(wr io.Writer, w *Workout) error {
+	_, err := io.WriteString(wr, "UPPER "+strings.ToUpper(w.Name))
+	return err
+}
+
+func init() {
+	RegisterFormat(upperFormat{})
+}
+
+func TestDetectFormat(t *testing.T) {
+	fitData, err := ioutil.ReadFile("testdata/repeats.fit")
+	if err != nil {
+		t.Fatalf("Could not read testdata/repeats.fit")
+	}
+	w, err := ReadFit("testdata/repeats.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	jsonData, _ := w.ToJSON()
+	yamlData, _ := w.ToYAML()
+
+	tests := []struct {
+		data     []byte
+		filename string
+		want     string
+	}{
+		{fitData, "", "fit"},
+		{fitData, "workout.json", "fit"},
+		{jsonData, "", "json"},
+		{jsonData, "workout.yaml", "json"}, // detected before the extension
+		{yamlData, "", "yaml"},
+		{yamlData, "workout.yml", "yaml"},
+		{[]byte("UPPER name"), "", "upper"},
+		{[]byte("- 10m Z2"), "workout.txt", "intervals"},
+	}
+	for _, test := range tests {
+		f, err := DetectFormat(test.data, test.filename)
+		if err != nil {
+			t.Errorf("%q: DetectFormat returned an error: %v", test.filename, err)
+			continue
+		}
+		if f.Name() != test.want {
+			t.Errorf("%q: got format %v, wanted %v", test.filename, f.Name(), test.want)
+		}
+	}
+
+	_, err = DetectFormat([]byte("hello"), "workout.doc")
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Got %v, wanted ErrUnknownFormat", err)
+	}
+}
+
+func TestLookupFormat(t *testing.T) {
+	for _, name := range []string{"fit", "FIT", ".fit", ".yml", "intervals", "upper"} {
+		_, err := LookupFormat(name)
+		if err != nil {
+			t.Errorf("LookupFormat(%q) returned an error: %v", name, err)
+		}
+	}
+	_, err := LookupFormat("zwo")
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Got %v, wanted ErrUnknownFormat", err)
+	}
+}
+
+func TestRegisterFormatTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a format twice did not panic")
+		}
+	}()
+	RegisterFormat(upperFormat{})
+}
+
+func TestConvert(t *testing.T) {
+	fitData, err := ioutil.ReadFile("testdata/nestedrepeats.fit")
+	if err != nil {
+		t.Fatalf("Could not read testdata/nestedrepeats.fit")
+	}
+	w, err := ReadFit("testdata/nestedrepeats.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	w.Filename = ""
+
+	// fit -> yaml -> json -> fit
+	var yamlData, jsonData, back bytes.Buffer
+	err = Convert(bytes.NewReader(fitData), &yamlData, "", "yaml")
+	if err != nil {
+		t.Fatalf("Convert to YAML returned an error: %v", err)
+	}
+	err = Convert(&yamlData, &jsonData, "", ".json")
+	if err != nil {
+		t.Fatalf("Convert to JSON returned an error: %v", err)
+	}
+	err = Convert(&jsonData, &back, "json", "fit")
+	if err != nil {
+		t.Fatalf("Convert to FIT returned an error: %v", err)
+	}
+	got, err := DecodeWorkout(&back)
+	if err != nil {
+		t.Fatalf("Could not decode the converted file: %v", err)
+	}
+	checkSameSteps(t, "converted workout", w, got)
+
+	var text bytes.Buffer
+	err = Convert(bytes.NewReader(fitData), &text, "fit", "intervals")
+	if err != nil {
+		t.Fatalf("Convert to intervals returned an error: %v", err)
+	}
+	want, _ := w.ToIntervals()
+	if text.String() != want {
+		t.Errorf("Got %q, wanted %q", text.String(), want)
+	}
+	err = Convert(&text, ioutil.Discard, "intervals", "fit")
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Got %v, wanted ErrNotSupported", err)
+	}
+
+	var upper bytes.Buffer
+	err = Convert(bytes.NewReader(fitData), &upper, "", "upper")
+	if err != nil || upper.String() != "UPPER "+strings.ToUpper(w.Name) {
+		t.Errorf("Got %q, %v from a registered format", upper.String(), err)
+	}
+}
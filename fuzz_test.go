@@ -0,0 +1,117 @@
+package goworkouts
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Run a fuzz target with go test -run '^$' -fuzz FuzzDecodeWorkout
+
+// addSeeds adds the files matching the patterns as seeds
+func addSeeds(f *testing.F, patterns ...string) {
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatalf("Could not list %v", pattern)
+		}
+		for _, path := range paths {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				f.Fatalf("Could not read %v", path)
+			}
+			f.Add(data)
+		}
+	}
+}
+
+// exercise runs the exports on a workout that was read without error
+func exercise(t *testing.T, w Workout) {
+	_, err := w.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON returned an error: %v", err)
+	}
+	_, err = w.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML returned an error: %v", err)
+	}
+	_, _ = w.ToIntervals()
+	_, _ = w.MarshalFIT(FITOptions{Reproducible: true})
+}
+
+// FuzzDecodeWorkout checks that no FIT file makes DecodeWorkout, which
+// ReadFit uses, panic
+func FuzzDecodeWorkout(f *testing.F) {
+	addSeeds(f, "testdata/*.fit", "testdata/fitsdk/*.fit")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		w, err := DecodeWorkout(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		exercise(t, w)
+	})
+}
+
+func FuzzFromJSON(f *testing.F) {
+	addSeeds(f, "testdata/golden/*.json")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		w, err := FromJSON(string(data))
+		if err != nil {
+			return
+		}
+		exercise(t, w)
+	})
+}
+
+func FuzzFromYAML(f *testing.F) {
+	addSeeds(f, "testdata/golden/*.yaml")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		w, err := FromYAML(string(data))
+		if err != nil {
+			return
+		}
+		exercise(t, w)
+	})
+}
+
+func FuzzTransformRepeats(f *testing.F) {
+	addSeeds(f, "testdata/golden/*.intervals.txt")
+	f.Add([]byte("\n3x\n\n4x\n\n5x\nhello\nrest\n\n6x\n\n7x\n"))
+	f.Add([]byte("3x"))
+	f.Add([]byte("- 60s Z2\n2x\n\n\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		input := string(data)
+		done := make(chan string)
+		go func() {
+			done <- TransformRepeats(input)
+		}()
+		select {
+		case output := <-done:
+			// every input line gives at most a marker and a line
+			if strings.Count(output, "\n") > 3*(strings.Count(input, "\n")+1) {
+				t.Errorf("Output has more lines than expected")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("TransformRepeats did not return")
+		}
+	})
+}
+
+func TestTransformRepeatsLastLine(t *testing.T) {
+	done := make(chan string)
+	go func() {
+		done <- TransformRepeats("- 60s Z2\n3x\n\n2x")
+	}()
+	select {
+	case got := <-done:
+		want := "- 60s Z2\n\n6x\n"
+		if got != want {
+			t.Errorf("Got %q, wanted %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TransformRepeats did not return")
+	}
+}
@@ -0,0 +1,600 @@
+package goworkouts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/tormoder/fit"
+)
+
+// The Garmin Connect workout service describes workouts in its own JSON
+// schema. Repeats are nested groups of steps instead of FIT repeat steps, and
+// the sport, step, end condition and target types are objects with an ID and
+// a key. Only the keys are used when reading.
+
+// GarminSportType is the sport of a Garmin Connect workout or segment
+type GarminSportType struct {
+	SportTypeID  int    `json:"sportTypeId"`
+	SportTypeKey string `json:"sportTypeKey"`
+}
+
+// GarminStepType is the type of a step, like warmup, interval or repeat
+type GarminStepType struct {
+	StepTypeID  int    `json:"stepTypeId"`
+	StepTypeKey string `json:"stepTypeKey"`
+}
+
+// GarminCondition is the end condition of a step, like time or distance
+type GarminCondition struct {
+	ConditionTypeID  int    `json:"conditionTypeId"`
+	ConditionTypeKey string `json:"conditionTypeKey"`
+}
+
+// GarminTargetType is the target of a step, like heart.rate.zone
+type GarminTargetType struct {
+	WorkoutTargetTypeID  int    `json:"workoutTargetTypeId"`
+	WorkoutTargetTypeKey string `json:"workoutTargetTypeKey"`
+}
+
+// GarminStep is a step of a Garmin Connect workout. Type is
+// ExecutableStepDTO for a step or RepeatGroupDTO for a group of steps that is
+// repeated NumberOfIterations times. End condition values are in s, m, kcal
+// or bpm, speed targets in m/s, power targets in W and heart rate targets in
+// bpm.
+type GarminStep struct {
+	Type                string            `json:"type"`
+	StepID              int               `json:"stepId"`
+	StepOrder           int               `json:"stepOrder"`
+	StepType            GarminStepType    `json:"stepType"`
+	ChildStepID         int               `json:"childStepId,omitempty"`
+	Description         string            `json:"description,omitempty"`
+	EndCondition        GarminCondition   `json:"endCondition"`
+	EndConditionValue   float64           `json:"endConditionValue,omitempty"`
+	EndConditionCompare string            `json:"endConditionCompare,omitempty"` // lt or gt
+	TargetType          *GarminTargetType `json:"targetType,omitempty"`
+	TargetValueOne      float64           `json:"targetValueOne,omitempty"`
+	TargetValueTwo      float64           `json:"targetValueTwo,omitempty"`
+	ZoneNumber          int               `json:"zoneNumber,omitempty"`
+	NumberOfIterations  int               `json:"numberOfIterations,omitempty"`
+	SmartRepeat         bool              `json:"smartRepeat,omitempty"`
+	WorkoutSteps        []GarminStep      `json:"workoutSteps,omitempty"`
+}
+
+// GarminSegment is a segment of a Garmin Connect workout. Multisport
+// workouts have a segment per sport.
+type GarminSegment struct {
+	SegmentOrder int             `json:"segmentOrder"`
+	SportType    GarminSportType `json:"sportType"`
+	WorkoutSteps []GarminStep    `json:"workoutSteps"`
+}
+
+// GarminWorkout is a workout of the Garmin Connect workout service
+type GarminWorkout struct {
+	WorkoutID       int64           `json:"workoutId,omitempty"`
+	WorkoutName     string          `json:"workoutName"`
+	Description     string          `json:"description,omitempty"`
+	SportType       GarminSportType `json:"sportType"`
+	WorkoutSegments []GarminSegment `json:"workoutSegments"`
+}
+
+const (
+	garminExecutableStep = "ExecutableStepDTO"
+	garminRepeatGroup    = "RepeatGroupDTO"
+)
+
+var garminSports = map[string]GarminSportType{
+	"running":  {1, "running"},
+	"cycling":  {2, "cycling"},
+	"generic":  {3, "other"},
+	"swimming": {4, "swimming"},
+	"hitt":     {9, "hiit"},
+	"multi":    {10, "multi_sport"},
+}
+
+var garminStepTypes = map[string]GarminStepType{
+	"Warmup":   {1, "warmup"},
+	"Cooldown": {2, "cooldown"},
+	"Active":   {3, "interval"},
+	"Recovery": {4, "recovery"},
+	"Rest":     {5, "rest"},
+	"Repeat":   {6, "repeat"},
+	"Other":    {7, "other"},
+}
+
+var garminConditions = map[string]GarminCondition{
+	"Open":       {1, "lap.button"},
+	"Time":       {2, "time"},
+	"Distance":   {3, "distance"},
+	"Calories":   {4, "calories"},
+	"HeartRate":  {6, "heart.rate"},
+	"Iterations": {7, "iterations"},
+}
+
+var garminTargets = map[string]GarminTargetType{
+	"Open":      {1, "no.target"},
+	"Power":     {2, "power.zone"},
+	"Cadence":   {3, "cadence"},
+	"HeartRate": {4, "heart.rate.zone"},
+	"Speed":     {5, "speed.zone"},
+	"Pace":      {6, "pace.zone"},
+}
+
+// garminTarget returns the target type of a FIT target type
+func garminTarget(name string) *GarminTargetType {
+	t := garminTargets[name]
+	return &t
+}
+
+// garminSport returns the sport of a Garmin sport key, or "" if unknown
+func garminSport(key string) string {
+	for name, t := range garminSports {
+		if t.SportTypeKey == key {
+			return name
+		}
+	}
+	return ""
+}
+
+// garminIntensity returns the intensity of a Garmin step type key, or "" if
+// unknown
+func garminIntensity(key string) string {
+	for name, t := range garminStepTypes {
+		if t.StepTypeKey == key {
+			return name
+		}
+	}
+	return ""
+}
+
+// garminWriter converts the steps of a workout and notes what it loses
+type garminWriter struct {
+	steps   []WorkoutStep
+	targets []int
+	report  *FidelityReport
+}
+
+// customRange returns the custom target range of a step with offset
+// subtracted, or false if the values are below offset
+func customRange(step WorkoutStep, offset uint32) (float64, float64, bool) {
+	if step.CustomTargetValueLow < offset || step.CustomTargetValueHigh <= offset {
+		return 0, 0, false
+	}
+	return float64(step.CustomTargetValueLow - offset), float64(step.CustomTargetValueHigh - offset), true
+}
+
+// setTarget sets the target of the Garmin step of step i
+func (gw *garminWriter) setTarget(g *GarminStep, i int, step WorkoutStep) {
+	g.TargetType = garminTarget("Open")
+	zone := step.TargetValue > 0 || (step.CustomTargetValueLow == 0 && step.CustomTargetValueHigh == 0)
+	switch step.TargetType {
+	case "Open":
+	case "Power", "PowerLap", "Power3s", "Power10s", "Power30s", "HeartRate", "HeartRateLap":
+		name, offset, unit := "Power", uint32(1000), "W"
+		if step.TargetType == "HeartRate" || step.TargetType == "HeartRateLap" {
+			name, offset, unit = "HeartRate", 100, "bpm"
+		}
+		if step.TargetType != name {
+			gw.report.add(i, "TargetType", LossRewritten, "Garmin Connect has no %v target, it is written as %v", step.TargetType, name)
+		}
+		if zone {
+			g.TargetType = garminTarget(name)
+			g.ZoneNumber = int(step.TargetValue)
+			return
+		}
+		low, high, ok := customRange(step, offset)
+		if !ok {
+			gw.report.add(i, "TargetType", LossDropped, "Garmin Connect has %v targets in %v only, not in percent", name, unit)
+			return
+		}
+		g.TargetType = garminTarget(name)
+		g.TargetValueOne, g.TargetValueTwo = low, high
+	case "Speed", "SpeedLap":
+		if step.TargetType != "Speed" {
+			gw.report.add(i, "TargetType", LossRewritten, "Garmin Connect has no %v target, it is written as Speed", step.TargetType)
+		}
+		g.TargetType = garminTarget("Speed")
+		if zone {
+			g.ZoneNumber = int(step.TargetValue)
+			return
+		}
+		g.TargetValueOne = float64(step.CustomTargetValueLow) / 1000
+		g.TargetValueTwo = float64(step.CustomTargetValueHigh) / 1000
+	case "Cadence":
+		g.TargetType = garminTarget("Cadence")
+		if step.TargetValue > 0 {
+			gw.report.add(i, "TargetValue", LossRewritten, "Garmin Connect has no cadence zones, the value is written as a range")
+			g.TargetValueOne, g.TargetValueTwo = float64(step.TargetValue), float64(step.TargetValue)
+			return
+		}
+		g.TargetValueOne, g.TargetValueTwo = float64(step.CustomTargetValueLow), float64(step.CustomTargetValueHigh)
+	default:
+		gw.report.add(i, "TargetType", LossDropped, "Garmin Connect has no %v target", step.TargetType)
+	}
+}
+
+// executable returns the Garmin step of step i
+func (gw *garminWriter) executable(i int) GarminStep {
+	step := gw.steps[i]
+	g := GarminStep{Type: garminExecutableStep, Description: step.Notes}
+	if step.WktStepName != "" {
+		gw.report.add(i, "WktStepName", LossDropped, "Garmin Connect steps have no name")
+	}
+
+	stepType, ok := garminStepTypes[step.Intensity]
+	if !ok || step.Intensity == "Repeat" {
+		gw.report.add(i, "Intensity", LossRewritten, "Garmin Connect has no %v steps, it is written as an interval", step.Intensity)
+		stepType = garminStepTypes["Active"]
+	}
+	g.StepType = stepType
+
+	g.EndCondition = garminConditions["Open"]
+	switch step.DurationType {
+	case "Open":
+	case "Time":
+		g.EndCondition = garminConditions["Time"]
+		g.EndConditionValue = float64(step.DurationValue) / 1000
+	case "Distance":
+		g.EndCondition = garminConditions["Distance"]
+		g.EndConditionValue = float64(step.DurationValue) / 100
+	case "Calories":
+		g.EndCondition = garminConditions["Calories"]
+		g.EndConditionValue = float64(step.DurationValue)
+	case "HrLessThan", "HrGreaterThan":
+		if step.DurationValue <= 100 {
+			gw.report.add(i, "DurationType", LossDropped, "Garmin Connect ends steps on heart rate in bpm only, the step ends on the lap button")
+			break
+		}
+		g.EndCondition = garminConditions["HeartRate"]
+		g.EndConditionValue = float64(step.DurationValue - 100)
+		g.EndConditionCompare = "lt"
+		if step.DurationType == "HrGreaterThan" {
+			g.EndConditionCompare = "gt"
+		}
+	default:
+		gw.report.add(i, "DurationType", LossDropped, "Garmin Connect has no %v end condition, the step ends on the lap button", step.DurationType)
+	}
+
+	gw.setTarget(&g, i, step)
+	return g
+}
+
+// repeatGroup returns the Garmin group of repeat step i with its steps
+func (gw *garminWriter) repeatGroup(i int, children []GarminStep) GarminStep {
+	step := gw.steps[i]
+	iterations := repeatCount(step)
+	if step.DurationType != "RepeatUntilStepsCmplt" {
+		gw.report.add(i, "DurationType", LossRewritten, "Garmin Connect only repeats a number of times, %v is written as a single pass", step.DurationType)
+	}
+	if step.WktStepName != "" {
+		gw.report.add(i, "WktStepName", LossDropped, "Garmin Connect steps have no name")
+	}
+	if step.TargetType != "Open" {
+		gw.report.add(i, "TargetType", LossDropped, "Garmin Connect repeat groups have no target")
+	}
+	if step.Intensity != "Active" {
+		gw.report.add(i, "Intensity", LossDropped, "Garmin Connect repeat groups have no intensity")
+	}
+	return GarminStep{
+		Type:               garminRepeatGroup,
+		StepType:           garminStepTypes["Repeat"],
+		Description:        step.Notes,
+		EndCondition:       garminConditions["Iterations"],
+		EndConditionValue:  float64(iterations),
+		NumberOfIterations: int(iterations),
+		WorkoutSteps:       children,
+	}
+}
+
+// build returns the Garmin steps of the steps at positions lo to hi
+// (exclusive). A repeat block becomes a group of the steps it repeats.
+func (gw *garminWriter) build(lo, hi int) []GarminStep {
+	var out []GarminStep
+	for i := lo; i < hi; {
+		// the outermost block that starts at i
+		end := -1
+		for r := hi - 1; r > i; r-- {
+			if gw.targets[r] == i {
+				end = r
+				break
+			}
+		}
+		if end < 0 {
+			out = append(out, gw.executable(i))
+			i++
+			continue
+		}
+		out = append(out, gw.repeatGroup(end, gw.build(i, end)))
+		i = end + 1
+	}
+	return out
+}
+
+// numberGarminSteps sets the step IDs and orders in the order of the steps,
+// and the child step IDs of the groups and the steps they hold
+func numberGarminSteps(steps []GarminStep, order *int, child *int, parent int) {
+	for i := range steps {
+		*order++
+		steps[i].StepID = *order
+		steps[i].StepOrder = *order
+		steps[i].ChildStepID = parent
+		if steps[i].Type == garminRepeatGroup {
+			*child++
+			steps[i].ChildStepID = *child
+			numberGarminSteps(steps[i].WorkoutSteps, order, child, *child)
+		}
+	}
+}
+
+// toGarmin converts the workout and reports what is lost on the way
+func (w *Workout) toGarmin() (GarminWorkout, FidelityReport, error) {
+	report := FidelityReport{Format: "garminconnect"}
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return GarminWorkout{}, report, err
+	}
+	err = checkNesting(w.Steps, targets)
+	if err != nil {
+		return GarminWorkout{}, report, err
+	}
+
+	sport, ok := garminSports[w.Sport]
+	if !ok {
+		sport = garminSports["generic"]
+		if w.Sport != "" {
+			report.add(-1, "Sport", LossRewritten, "Garmin Connect has no %v workouts, it is written as other", w.Sport)
+		}
+	}
+	workoutFields := []struct {
+		name string
+		set  bool
+	}{
+		{"SubSport", w.SubSport != ""},
+		{"PoolLength", w.PoolLength != 0},
+		{"Capabilities", w.Capabilities != 0},
+		{"Extensions", !w.Extensions.empty()},
+	}
+	for _, field := range workoutFields {
+		if field.set {
+			report.add(-1, field.name, LossDropped, "Garmin Connect workouts have no %v", field.name)
+		}
+	}
+	for i, step := range w.Steps {
+		if int(step.MessageIndex) != i {
+			report.add(i, "MessageIndex", LossRewritten, "Garmin Connect numbers the steps in order")
+		}
+		if !step.Extensions.empty() {
+			report.add(i, "Extensions", LossDropped, "Garmin Connect steps have no FIT extensions")
+		}
+	}
+
+	gw := garminWriter{steps: w.Steps, targets: targets, report: &report}
+	steps := gw.build(0, len(w.Steps))
+	order, child := 0, 0
+	numberGarminSteps(steps, &order, &child, 0)
+
+	return GarminWorkout{
+		WorkoutName: w.Name,
+		Description: w.Description,
+		SportType:   sport,
+		WorkoutSegments: []GarminSegment{
+			{SegmentOrder: 1, SportType: sport, WorkoutSteps: steps},
+		},
+	}, report, nil
+}
+
+// ToGarminConnectJSON exports to the JSON of the Garmin Connect workout
+// service. Use Fidelity(w, "garminconnect") to find out what it loses.
+func (w *Workout) ToGarminConnectJSON() ([]byte, error) {
+	g, _, err := w.toGarmin()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(g)
+}
+
+// garminValue returns a Garmin value times scale plus offset as a FIT value.
+// Negative values and values that do not fit return an error wrapping
+// ErrInvalidValue.
+func garminValue(v float64, scale float64, offset uint32) (uint32, error) {
+	x := math.Round(v*scale) + float64(offset)
+	if math.IsNaN(v) || v < 0 || x >= float64(MaxUint) {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidValue, v)
+	}
+	return uint32(x), nil
+}
+
+// garminRange returns a custom target range from Garmin values, with
+// offset added. Garmin does not always put the low value first.
+func garminRange(one, two float64, scale float64, offset uint32) (uint32, uint32, error) {
+	low, err := garminValue(math.Min(one, two), scale, offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	high, err := garminValue(math.Max(one, two), scale, offset)
+	return low, high, err
+}
+
+// fromGarminStep converts an executable Garmin step
+func fromGarminStep(g GarminStep, idx int) (WorkoutStep, error) {
+	step := newWorkoutStep()
+	step.MessageIndex = fit.MessageIndex(idx)
+	step.Notes = g.Description
+
+	step.Intensity = garminIntensity(g.StepType.StepTypeKey)
+	if step.Intensity == "" || step.Intensity == "Repeat" {
+		return step, fmt.Errorf("Unknown Garmin Connect step type %q", g.StepType.StepTypeKey)
+	}
+
+	var err error
+	switch g.EndCondition.ConditionTypeKey {
+	case "lap.button":
+		step.DurationType = "Open"
+	case "time":
+		step.DurationType = "Time"
+		step.DurationValue, err = garminValue(g.EndConditionValue, 1000, 0)
+	case "distance":
+		step.DurationType = "Distance"
+		step.DurationValue, err = garminValue(g.EndConditionValue, 100, 0)
+	case "calories":
+		step.DurationType = "Calories"
+		step.DurationValue, err = garminValue(g.EndConditionValue, 1, 0)
+	case "heart.rate":
+		step.DurationType = "HrLessThan"
+		if g.EndConditionCompare == "gt" {
+			step.DurationType = "HrGreaterThan"
+		}
+		step.DurationValue, err = garminValue(g.EndConditionValue, 1, 100)
+	default:
+		return step, fmt.Errorf("Unknown Garmin Connect end condition %q", g.EndCondition.ConditionTypeKey)
+	}
+	if err != nil {
+		return step, fmt.Errorf("End condition value: %w", err)
+	}
+
+	step.TargetType = "Open"
+	if g.TargetType == nil {
+		return step, nil
+	}
+	zone := g.ZoneNumber > 0 || (g.TargetValueOne == 0 && g.TargetValueTwo == 0)
+	switch g.TargetType.WorkoutTargetTypeKey {
+	case "no.target":
+	case "power.zone", "heart.rate.zone", "speed.zone", "pace.zone":
+		scale, offset := 1.0, uint32(1000)
+		switch g.TargetType.WorkoutTargetTypeKey {
+		case "power.zone":
+			step.TargetType = "Power"
+		case "heart.rate.zone":
+			step.TargetType, offset = "HeartRate", 100
+		default:
+			step.TargetType, scale, offset = "Speed", 1000, 0
+		}
+		if zone {
+			step.TargetValue, err = garminValue(float64(g.ZoneNumber), 1, 0)
+			break
+		}
+		step.CustomTargetValueLow, step.CustomTargetValueHigh, err = garminRange(g.TargetValueOne, g.TargetValueTwo, scale, offset)
+	case "cadence":
+		step.TargetType = "Cadence"
+		step.CustomTargetValueLow, step.CustomTargetValueHigh, err = garminRange(g.TargetValueOne, g.TargetValueTwo, 1, 0)
+	default:
+		return step, fmt.Errorf("Unknown Garmin Connect target type %q", g.TargetType.WorkoutTargetTypeKey)
+	}
+	if err != nil {
+		return step, fmt.Errorf("Target value: %w", err)
+	}
+	return step, nil
+}
+
+// appendGarminSteps appends the FIT steps of Garmin steps to steps. A group
+// becomes its steps followed by a repeat step.
+func appendGarminSteps(steps []WorkoutStep, gs []GarminStep) ([]WorkoutStep, error) {
+	for _, g := range gs {
+		switch g.Type {
+		case garminRepeatGroup:
+			if len(g.WorkoutSteps) == 0 {
+				continue
+			}
+			first := len(steps)
+			var err error
+			steps, err = appendGarminSteps(steps, g.WorkoutSteps)
+			if err != nil {
+				return nil, err
+			}
+			iterations, err := garminValue(float64(g.NumberOfIterations), 1, 0)
+			if err == nil && iterations == 0 {
+				iterations, err = garminValue(math.Trunc(g.EndConditionValue), 1, 0)
+			}
+			if err != nil {
+				return nil, &StepError{Index: len(steps), Field: "TargetValue", Err: fmt.Errorf("%w: iterations: %w", ErrInvalidRepeat, err)}
+			}
+			if uint64(first) >= uint64(MaxUint) {
+				return nil, &StepError{Index: len(steps), Field: "DurationValue", Err: fmt.Errorf("%w: too many steps", ErrInvalidRepeat)}
+			}
+			repeat := newWorkoutStep()
+			repeat.MessageIndex = fit.MessageIndex(len(steps))
+			repeat.DurationType = "RepeatUntilStepsCmplt"
+			repeat.DurationValue = uint32(first)
+			repeat.TargetType = "Open"
+			repeat.TargetValue = iterations
+			repeat.Intensity = "Active"
+			repeat.Notes = g.Description
+			steps = append(steps, repeat)
+		case garminExecutableStep:
+			step, err := fromGarminStep(g, len(steps))
+			if err != nil {
+				return nil, &StepError{Index: len(steps), Err: err}
+			}
+			steps = append(steps, step)
+		default:
+			return nil, fmt.Errorf("Unknown Garmin Connect step %q", g.Type)
+		}
+	}
+	return steps, nil
+}
+
+// FromGarminConnectJSON returns workout from the JSON of the Garmin Connect
+// workout service. The steps of all segments are joined.
+func FromGarminConnectJSON(s string) (Workout, error) {
+	var g GarminWorkout
+	err := json.Unmarshal([]byte(s), &g)
+	if err != nil {
+		return Workout{}, err
+	}
+	w := Workout{
+		Name:        g.WorkoutName,
+		Description: g.Description,
+		Sport:       garminSport(g.SportType.SportTypeKey),
+	}
+	if w.Sport == "" {
+		w.Sport = "generic"
+	}
+	for _, segment := range g.WorkoutSegments {
+		w.Steps, err = appendGarminSteps(w.Steps, segment.WorkoutSteps)
+		if err != nil {
+			return Workout{}, err
+		}
+	}
+	return w, nil
+}
+
+// garminConnectFormat is the JSON of the Garmin Connect workout service
+type garminConnectFormat struct{}
+
+func (garminConnectFormat) Name() string         { return "garminconnect" }
+func (garminConnectFormat) Extensions() []string { return []string{".json"} }
+
+func (garminConnectFormat) Detect(data []byte) bool {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) != nil {
+		return false
+	}
+	_, ok := object["workoutSegments"]
+	return ok
+}
+
+func (garminConnectFormat) Decode(r io.Reader) (Workout, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Workout{}, err
+	}
+	return FromGarminConnectJSON(string(data))
+}
+
+func (garminConnectFormat) Encode(wr io.Writer, w *Workout) error {
+	data, err := w.ToGarminConnectJSON()
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(data)
+	return err
+}
+
+// Fidelity is empty for workouts with invalid repeats, which cannot be
+// exported at all
+func (garminConnectFormat) Fidelity(w *Workout) FidelityReport {
+	_, report, _ := w.toGarmin()
+	return report
+}
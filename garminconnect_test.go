@@ -0,0 +1,191 @@
+package goworkouts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fitSteps returns the steps of a workout as they come back from a FIT file
+func fitSteps(t *testing.T, w Workout) []WorkoutStep {
+	t.Helper()
+	data, err := w.MarshalFIT(FITOptions{Reproducible: true})
+	if err != nil {
+		t.Fatalf("MarshalFIT returned an error: %v", err)
+	}
+	back, err := DecodeWorkout(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeWorkout returned an error: %v", err)
+	}
+	return back.Steps
+}
+
+// lostFields are the step fields that change with a loss of a field
+var lostFields = map[string][]string{
+	"DurationType": {"DurationType", "DurationValue", "TargetValue"},
+	"TargetType":   {"TargetType", "TargetValue", "CustomTargetValueLow", "CustomTargetValueHigh"},
+	"TargetValue":  {"TargetValue", "CustomTargetValueLow", "CustomTargetValueHigh"},
+}
+
+// clearLost zeroes the fields of the steps that the report says are lost
+func clearLost(steps []WorkoutStep, report FidelityReport) {
+	for _, loss := range report.Losses {
+		if loss.Step < 0 || loss.Step >= len(steps) {
+			continue
+		}
+		fields, ok := lostFields[loss.Field]
+		if !ok {
+			fields = []string{loss.Field}
+		}
+		v := reflect.ValueOf(&steps[loss.Step]).Elem()
+		for _, field := range fields {
+			f := v.FieldByName(field)
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+}
+
+// TestGarminConnectRoundTrip converts every FIT workout in testdata to
+// Garmin Connect JSON and back. The steps must come back the same, except
+// for the fields the fidelity report lists.
+func TestGarminConnectRoundTrip(t *testing.T) {
+	paths, _ := filepath.Glob("testdata/*.fit")
+	sdk, _ := filepath.Glob("testdata/fitsdk/*.fit")
+	for _, path := range append(paths, sdk...) {
+		if !isWorkoutFile(path) {
+			continue
+		}
+		t.Run(goldenName(path), func(t *testing.T) {
+			w, err := ReadFit(path)
+			if err != nil {
+				t.Fatalf("ReadFit returned an error: %v", err)
+			}
+			data, err := w.ToGarminConnectJSON()
+			if err != nil {
+				t.Fatalf("ToGarminConnectJSON returned an error: %v", err)
+			}
+			back, err := FromGarminConnectJSON(string(data))
+			if err != nil {
+				t.Fatalf("FromGarminConnectJSON returned an error: %v", err)
+			}
+			report, err := Fidelity(&w, "garminconnect")
+			if err != nil {
+				t.Fatalf("Fidelity returned an error: %v", err)
+			}
+
+			want := fitSteps(t, w)
+			got := fitSteps(t, back)
+			if len(got) != len(want) {
+				t.Fatalf("Got %d steps, wanted %d", len(got), len(want))
+			}
+			clearLost(want, report)
+			clearLost(got, report)
+			for i := range want {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Errorf("Step %d differs in a field that is not in the report\nGot:  %+v\nWant: %+v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGarminConnectJSON(t *testing.T) {
+	w := Workout{
+		Name:        "Threshold",
+		Sport:       "cycling",
+		Description: "Over-unders",
+		Steps: []WorkoutStep{
+			{MessageIndex: 0, DurationType: "Time", DurationValue: 600000, TargetType: "HeartRate", TargetValue: 2, Intensity: "Warmup"},
+			{MessageIndex: 1, DurationType: "Time", DurationValue: 120000, TargetType: "Power", CustomTargetValueLow: 1280, CustomTargetValueHigh: 1300, Intensity: "Active", Notes: "over"},
+			{MessageIndex: 2, DurationType: "Distance", DurationValue: 50000, TargetType: "Cadence", CustomTargetValueLow: 85, CustomTargetValueHigh: 95, Intensity: "Recovery"},
+			{MessageIndex: 3, DurationType: "RepeatUntilStepsCmplt", DurationValue: 1, TargetType: "Open", TargetValue: 3, Intensity: "Active"},
+			{MessageIndex: 4, DurationType: "HrLessThan", DurationValue: 220, TargetType: "Speed", CustomTargetValueLow: 2500, CustomTargetValueHigh: 3250, Intensity: "Rest"},
+			{MessageIndex: 5, DurationType: "RepeatUntilStepsCmplt", DurationValue: 0, TargetType: "Open", TargetValue: 2, Intensity: "Active"},
+			{MessageIndex: 6, DurationType: "Open", TargetType: "Open", Intensity: "Cooldown"},
+		},
+	}
+	data, err := w.ToGarminConnectJSON()
+	if err != nil {
+		t.Fatalf("ToGarminConnectJSON returned an error: %v", err)
+	}
+	checkGoldenJSON(t, "garminconnect.json", data)
+
+	var g GarminWorkout
+	err = json.Unmarshal(data, &g)
+	if err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	outer := g.WorkoutSegments[0].WorkoutSteps
+	if len(outer) != 2 || outer[0].Type != garminRepeatGroup || outer[0].NumberOfIterations != 2 {
+		t.Fatalf("Wrong outer steps %+v", outer)
+	}
+	inner := outer[0].WorkoutSteps
+	if len(inner) != 3 || inner[1].Type != garminRepeatGroup || len(inner[1].WorkoutSteps) != 2 {
+		t.Fatalf("Wrong nested repeat %+v", inner)
+	}
+
+	report, _ := Fidelity(&w, "garminconnect")
+	if !report.Lossless() {
+		t.Errorf("Expected a lossless export, got %+v", report.Losses)
+	}
+	back, err := FromGarminConnectJSON(string(data))
+	if err != nil {
+		t.Fatalf("FromGarminConnectJSON returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(back, w) {
+		t.Errorf("Round trip changed the workout\nGot:  %+v\nWant: %+v", back, w)
+	}
+
+	f, err := DetectFormat(data, "workout.json")
+	if err != nil || f.Name() != "garminconnect" {
+		t.Errorf("Garmin Connect JSON is not detected: %v", err)
+	}
+}
+
+func TestGarminConnectErrors(t *testing.T) {
+	tests := []string{
+		`{"workoutSegments":[{"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"fixed.rest"}}]}]}`,
+		`{"workoutSegments":[{"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"sprint"},"endCondition":{"conditionTypeKey":"time"}}]}]}`,
+		`{"workoutSegments":[{"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"time"},"targetType":{"workoutTargetTypeKey":"grade"}}]}]}`,
+		`{"workoutSegments":[{"workoutSteps":[{"type":"CircuitDTO"}]}]}`,
+	}
+	for _, test := range tests {
+		_, err := FromGarminConnectJSON(test)
+		if err == nil {
+			t.Errorf("Expected an error for %v", test)
+		}
+	}
+
+	_, err := FromGarminConnectJSON(`{"workoutSegments":[{"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"time"},"targetType":{"workoutTargetTypeKey":"grade"}}]}]}`)
+	if !strings.Contains(err.Error(), "Step 0") {
+		t.Errorf("The error does not name the step: %v", err)
+	}
+
+	// negative and out of range values are not converted
+	invalid := []string{
+		`{"workoutSegments":[{"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"time"},"endConditionValue":-60}]}]}`,
+		`{"workoutSegments":[{"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"distance"},"endConditionValue":1e12}]}]}`,
+		`{"workoutSegments":[{"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"time"},"endConditionValue":60,"targetType":{"workoutTargetTypeKey":"power.zone"},"targetValueOne":-200,"targetValueTwo":250}]}]}`,
+		`{"workoutSegments":[{"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"time"},"endConditionValue":60,"targetType":{"workoutTargetTypeKey":"heart.rate.zone"},"zoneNumber":-2}]}]}`,
+	}
+	for _, test := range invalid {
+		_, err := FromGarminConnectJSON(test)
+		if !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("Expected ErrInvalidValue for %v, got %v", test, err)
+		}
+	}
+	repeats := []string{
+		`{"workoutSegments":[{"workoutSteps":[{"type":"RepeatGroupDTO","numberOfIterations":-3,"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"lap.button"}}]}]}]}`,
+		`{"workoutSegments":[{"workoutSteps":[{"type":"RepeatGroupDTO","endConditionValue":1e10,"workoutSteps":[{"type":"ExecutableStepDTO","stepType":{"stepTypeKey":"interval"},"endCondition":{"conditionTypeKey":"lap.button"}}]}]}]}`,
+	}
+	for _, test := range repeats {
+		_, err := FromGarminConnectJSON(test)
+		if !errors.Is(err, ErrInvalidRepeat) || !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("Expected ErrInvalidRepeat for %v, got %v", test, err)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package goworkouts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purpose is what a workout in a WorkoutLibrary trains
+type Purpose string
+
+// Purposes of library workouts
+const (
+	PurposeEndurance Purpose = "endurance"
+	PurposeThreshold Purpose = "threshold"
+	PurposeVO2       Purpose = "vo2"
+	PurposeRecovery  Purpose = "recovery"
+)
+
+// WorkoutLibrary holds the workouts GeneratePlan picks from, by purpose
+type WorkoutLibrary map[Purpose][]Workout
+
+// Phases of a generated plan
+const (
+	PhaseBase  = "base"
+	PhaseBuild = "build"
+	PhasePeak  = "peak"
+	PhaseTaper = "taper"
+)
+
+// PlanRequest describes the plan GeneratePlan builds
+type PlanRequest struct {
+	Name        string
+	Start       time.Time // first day of the plan
+	RaceDate    time.Time // last day of the plan
+	WeeklyHours float64   // training hours in a normal week
+	Sport       string
+	Library     WorkoutLibrary
+	// RecoveryEvery makes every n-th week of the base and build phases a
+	// recovery week. Defaults to 4.
+	RecoveryEvery int
+	// Tolerance is the allowed relative difference between the planned and
+	// the target weekly duration. Defaults to 0.05.
+	Tolerance float64
+	// Speed in m/s, used for the duration of distance steps. Defaults to the
+	// DefaultSpeeds of the sport.
+	Speed float64
+}
+
+// Volume of recovery and taper weeks relative to a normal week
+const (
+	recoveryWeekFactor = 0.6
+	taperWeekFactor    = 0.6
+)
+
+// sessionSlots are the days of the week sessions are put on, in order of use.
+// At least one day of the week is left as a rest day.
+var sessionSlots = []int{1, 3, 5, 6, 2, 4, 0}
+
+// keySessions are the workouts done every week of a phase, on top of the
+// endurance workouts that fill up the week
+var keySessions = map[string][]Purpose{
+	PhaseBase:  {PurposeThreshold},
+	PhaseBuild: {PurposeThreshold, PurposeVO2},
+	PhasePeak:  {PurposeVO2, PurposeThreshold},
+	PhaseTaper: {PurposeVO2, PurposeRecovery},
+}
+
+// planWeek is a week of a generated plan
+type planWeek struct {
+	phase    string
+	recovery bool
+	factor   float64 // volume relative to a normal week
+}
+
+// phaseSchedule divides the weeks over the phases, with a recovery week every
+// recoveryEvery weeks in the base and build phases
+func phaseSchedule(weeks int, recoveryEvery int) []planWeek {
+	taper := 1
+	if weeks >= 12 {
+		taper = 2
+	}
+	if taper > weeks {
+		taper = weeks
+	}
+	remaining := weeks - taper
+	peak := 0
+	if remaining >= 3 {
+		peak = int(math.Max(1, math.Round(float64(remaining)*0.15)))
+	}
+	build := int(math.Round(float64(remaining) * 0.35))
+	base := remaining - peak - build
+
+	var schedule []planWeek
+	add := func(phase string, n int, factor float64) {
+		for i := 0; i < n; i++ {
+			schedule = append(schedule, planWeek{phase: phase, factor: factor})
+		}
+	}
+	add(PhaseBase, base, 1)
+	add(PhaseBuild, build, 1)
+	add(PhasePeak, peak, 1)
+	add(PhaseTaper, taper, taperWeekFactor)
+
+	for i := range schedule[:base+build] {
+		if recoveryEvery > 0 && (i+1)%recoveryEvery == 0 {
+			schedule[i].recovery = true
+			schedule[i].factor = recoveryWeekFactor
+		}
+	}
+	return schedule
+}
+
+// describeSchedule summarizes the phases, like "Base weeks 1-6, build weeks
+// 7-9, taper week 10. Recovery weeks 4."
+func describeSchedule(schedule []planWeek) string {
+	var phases []string
+	var recovery []string
+	for i := 0; i < len(schedule); {
+		j := i
+		for j+1 < len(schedule) && schedule[j+1].phase == schedule[i].phase {
+			j++
+		}
+		if i == j {
+			phases = append(phases, fmt.Sprintf("%v week %d", schedule[i].phase, i+1))
+		} else {
+			phases = append(phases, fmt.Sprintf("%v weeks %d-%d", schedule[i].phase, i+1, j+1))
+		}
+		i = j + 1
+	}
+	for i, week := range schedule {
+		if week.recovery {
+			recovery = append(recovery, fmt.Sprint(i+1))
+		}
+	}
+	s := strings.Join(phases, ", ") + "."
+	if len(recovery) > 0 {
+		s += " Recovery weeks " + strings.Join(recovery, ", ") + "."
+	}
+	return capitalize(s)
+}
+
+// capitalize returns s with its first letter in upper case
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// planner picks the workouts of a generated plan
+type planner struct {
+	req       PlanRequest
+	library   WorkoutLibrary
+	durations map[Purpose][]time.Duration
+	next      map[Purpose]int
+}
+
+func newPlanner(req PlanRequest) (*planner, error) {
+	p := &planner{
+		req:       req,
+		library:   WorkoutLibrary{},
+		durations: map[Purpose][]time.Duration{},
+		next:      map[Purpose]int{},
+	}
+	for purpose, workouts := range req.Library {
+		for _, w := range workouts {
+			if w.Sport != req.Sport && !genericSport(w.Sport) {
+				continue
+			}
+			d, err := w.PlannedDuration(req.Speed)
+			if err != nil {
+				return nil, fmt.Errorf("Workout %q: %w", w.Name, err)
+			}
+			if d == 0 {
+				continue
+			}
+			p.library[purpose] = append(p.library[purpose], w)
+			p.durations[purpose] = append(p.durations[purpose], d)
+		}
+	}
+	if len(p.library[PurposeEndurance]) == 0 {
+		return nil, fmt.Errorf("Library has no %v workouts for %v with a known duration", PurposeEndurance, req.Sport)
+	}
+	return p, nil
+}
+
+// pick returns the next workout for purpose, falling back to endurance
+// workouts when the library has none
+func (p *planner) pick(purpose Purpose) (Workout, time.Duration) {
+	if len(p.library[purpose]) == 0 {
+		purpose = PurposeEndurance
+	}
+	i := p.next[purpose] % len(p.library[purpose])
+	p.next[purpose]++
+	w := p.library[purpose][i]
+	w.Steps = append([]WorkoutStep{}, w.Steps...)
+	return w, p.durations[purpose][i]
+}
+
+// averageEndurance returns the average duration of the endurance workouts
+func (p *planner) averageEndurance() time.Duration {
+	var total time.Duration
+	for _, d := range p.durations[PurposeEndurance] {
+		total += d
+	}
+	return total / time.Duration(len(p.durations[PurposeEndurance]))
+}
+
+// scaleAll scales the duration of the workouts so they add up to target
+func (p *planner) scaleAll(workouts []Workout, total time.Duration, target time.Duration) error {
+	factor := float64(target) / float64(total)
+	for i := range workouts {
+		err := workouts[i].ScaleDuration(factor)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// week returns at most sessions workouts for a week with the given target
+// duration
+func (p *planner) week(week planWeek, target time.Duration, sessions int) ([]Workout, error) {
+	purposes := keySessions[week.phase]
+	if week.recovery {
+		purposes = []Purpose{PurposeRecovery}
+	}
+	var keys []Workout
+	var keyTotal time.Duration
+	for _, purpose := range purposes {
+		w, d := p.pick(purpose)
+		keys = append(keys, w)
+		keyTotal += d
+	}
+
+	tolerance := time.Duration(float64(target) * p.req.Tolerance)
+	remaining := target - keyTotal
+	var fillers []Workout
+	var fillerTotal time.Duration
+	if remaining > tolerance && len(keys) < sessions {
+		n := int(math.Round(float64(remaining) / float64(p.averageEndurance())))
+		if n < 1 {
+			n = 1
+		}
+		if n > sessions-len(keys) {
+			n = sessions - len(keys)
+		}
+		for i := 0; i < n; i++ {
+			w, d := p.pick(PurposeEndurance)
+			fillers = append(fillers, w)
+			fillerTotal += d
+		}
+		err := p.scaleAll(fillers, fillerTotal, remaining)
+		if err != nil {
+			return nil, err
+		}
+	} else if remaining < -tolerance || remaining > tolerance {
+		err := p.scaleAll(keys, keyTotal, target)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(keys, fillers...), nil
+}
+
+// weekDuration adds up the planned duration of the workouts
+func weekDuration(workouts []Workout, speed float64) (time.Duration, error) {
+	var total time.Duration
+	for _, w := range workouts {
+		d, err := w.PlannedDuration(speed)
+		if err != nil {
+			return 0, err
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// GeneratePlan builds a TrainingPlan of whole weeks that ends on the race date
+// out of the workouts in the library. Day 1 of the plan is the start date. If
+// the time between the start and the race date is not a whole number of
+// weeks, the days before the first week are rest days, the StartOffset of the
+// plan. The weeks are divided over base, build, peak and taper phases,
+// with a recovery week every few weeks. Every week has a few
+// key workouts for its phase, and is filled up with endurance workouts that
+// are scaled so the planned duration of the week matches the weekly hours
+// within the tolerance.
+func GeneratePlan(req PlanRequest) (TrainingPlan, error) {
+	if req.RecoveryEvery == 0 {
+		req.RecoveryEvery = 4
+	}
+	if req.Tolerance == 0 {
+		req.Tolerance = 0.05
+	}
+	if req.WeeklyHours <= 0 {
+		return TrainingPlan{}, errors.New("Weekly hours must be positive")
+	}
+	start := time.Date(req.Start.Year(), req.Start.Month(), req.Start.Day(), 0, 0, 0, 0, time.UTC)
+	race := time.Date(req.RaceDate.Year(), req.RaceDate.Month(), req.RaceDate.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(race.Sub(start).Hours()/24) + 1
+	weeks := days / 7
+	if weeks < 1 {
+		return TrainingPlan{}, errors.New("Race date must be at least a week after the start")
+	}
+	// days that do not fill a whole week are rest days at the start
+	offset := days - 7*weeks
+
+	p, err := newPlanner(req)
+	if err != nil {
+		return TrainingPlan{}, err
+	}
+
+	schedule := phaseSchedule(weeks, req.RecoveryEvery)
+	normal := time.Duration(req.WeeklyHours * float64(time.Hour))
+	var trainingDays []TrainingDay
+	for i, week := range schedule {
+		target := time.Duration(float64(normal) * week.factor)
+		slots := sessionSlots
+		if i == len(schedule)-1 {
+			// no workouts on race day
+			slots = []int{1, 3, 5, 2, 4, 0}
+		}
+		workouts, err := p.week(week, target, len(slots)-1)
+		if err != nil {
+			return TrainingPlan{}, fmt.Errorf("Week %d: %w", i+1, err)
+		}
+		total, err := weekDuration(workouts, req.Speed)
+		if err != nil {
+			return TrainingPlan{}, fmt.Errorf("Week %d: %w", i+1, err)
+		}
+		if math.Abs(float64(total-target)) > float64(target)*req.Tolerance {
+			return TrainingPlan{}, fmt.Errorf("Week %d: planned %v does not match target %v", i+1, total, target)
+		}
+
+		var weekDays []TrainingDay
+		for j, w := range workouts {
+			order := uint32(offset + 7*i + slots[j] + 1)
+			weekDays = append(weekDays, TrainingDay{Order: order, Workouts: []Workout{w}})
+		}
+		sort.Slice(weekDays, func(a, b int) bool { return weekDays[a].Order < weekDays[b].Order })
+		trainingDays = append(trainingDays, weekDays...)
+	}
+
+	name := req.Name
+	if name == "" {
+		name = fmt.Sprintf("%v plan for %v", req.Sport, race.Format("2006-01-02"))
+	}
+	return TrainingPlan{
+		ID:           uuid.New(),
+		Name:         name,
+		TrainingDays: trainingDays,
+		Duration:     uint32(days),
+		StartOffset:  uint32(offset),
+		Description:  describeSchedule(schedule),
+		Weeks:        scheduleWeeks(schedule),
+		Phases:       schedulePhases(schedule),
+	}, nil
+}
+
+// phaseGoals are the goals of the phases of a generated plan
+var phaseGoals = map[string]string{
+	PhaseBase:  "Aerobic endurance",
+	PhaseBuild: "Threshold and VO2max",
+	PhasePeak:  "Race specific intensity",
+	PhaseTaper: "Arrive fresh at the race",
+}
+
+// scheduleWeeks returns the week descriptions of a generated plan
+func scheduleWeeks(schedule []planWeek) []PlanWeek {
+	var weeks []PlanWeek
+	for i, week := range schedule {
+		name := fmt.Sprintf("Week %d - %v", i+1, capitalize(week.phase))
+		if week.recovery {
+			name += ", recovery week"
+		}
+		weeks = append(weeks, PlanWeek{Number: uint32(i + 1), Name: name, Recovery: week.recovery})
+	}
+	return weeks
+}
+
+// schedulePhases returns the phases of a generated plan
+func schedulePhases(schedule []planWeek) []PlanPhase {
+	var phases []PlanPhase
+	for i, week := range schedule {
+		n := uint32(i + 1)
+		if len(phases) > 0 && phases[len(phases)-1].Name == week.phase {
+			phases[len(phases)-1].LastWeek = n
+			continue
+		}
+		phases = append(phases, PlanPhase{Name: week.phase, FirstWeek: n, LastWeek: n, Goal: phaseGoals[week.phase]})
+	}
+	return phases
+}
@@ -0,0 +1,123 @@
+package goworkouts
+
+import (
+	"testing"
+	"time"
+)
+
+func testLibrary(t *testing.T) WorkoutLibrary {
+	read := func(f string) Workout {
+		w, err := ReadFit(f)
+		if err != nil {
+			t.Fatalf("ReadFit returned an error")
+		}
+		return w
+	}
+	return WorkoutLibrary{
+		PurposeEndurance: {read("testdata/rowingworkout.fit"), read("testdata/4x15min.fit")},
+		PurposeThreshold: {read("testdata/4x15min.fit")},
+		PurposeVO2:       {read("testdata/nestedrepeats2.fit")},
+		PurposeRecovery:  {read("testdata/repeats.fit")},
+	}
+}
+
+func TestGeneratePlan(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	req := PlanRequest{
+		Start:       start,
+		RaceDate:    start.AddDate(0, 0, 16*7-1),
+		WeeklyHours: 6,
+		Sport:       "rowing",
+		Library:     testLibrary(t),
+	}
+	plan, err := GeneratePlan(req)
+	if err != nil {
+		t.Fatalf("GeneratePlan returned an error: %v", err)
+	}
+	if plan.Duration != 112 {
+		t.Errorf("Wanted a plan of 112 days, got %v", plan.Duration)
+	}
+	want := "Base weeks 1-7, build weeks 8-12, peak weeks 13-14, taper weeks 15-16. Recovery weeks 4, 8, 12."
+	if plan.Description != want {
+		t.Errorf("Wanted description %q, got %q", want, plan.Description)
+	}
+
+	weeks := make([]time.Duration, 16)
+	for _, day := range plan.TrainingDays {
+		if day.Order < 1 || day.Order >= plan.Duration {
+			t.Errorf("Day %d outside of the plan", day.Order)
+		}
+		for _, w := range day.Workouts {
+			d, err := w.PlannedDuration(0)
+			if err != nil {
+				t.Fatalf("PlannedDuration returned an error: %v", err)
+			}
+			weeks[(day.Order-1)/7] += d
+		}
+	}
+	for i, d := range weeks {
+		target := 6 * time.Hour
+		switch i + 1 {
+		case 4, 8, 12, 15, 16:
+			target = time.Duration(float64(target) * 0.6)
+		}
+		if d < target*95/100 || d > target*105/100 {
+			t.Errorf("Week %d: planned %v, wanted %v", i+1, d, target)
+		}
+	}
+}
+
+func TestGeneratePlanErrors(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	req := PlanRequest{
+		Start:       start,
+		RaceDate:    start.AddDate(0, 0, 3),
+		WeeklyHours: 6,
+		Sport:       "rowing",
+		Library:     testLibrary(t),
+	}
+	_, err := GeneratePlan(req)
+	if err == nil {
+		t.Errorf("A plan shorter than a week should have thrown an error")
+	}
+	req.RaceDate = start.AddDate(0, 0, 60)
+	req.Sport = "running"
+	req.Library = WorkoutLibrary{PurposeEndurance: req.Library[PurposeRecovery]}
+	_, err = GeneratePlan(req)
+	if err == nil {
+		t.Errorf("A library without endurance workouts should have thrown an error")
+	}
+}
+
+func TestGeneratePlanWeeks(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	req := PlanRequest{
+		Start:       start,
+		RaceDate:    start.AddDate(0, 0, 10*7+2),
+		WeeklyHours: 5,
+		Sport:       "rowing",
+		Library:     testLibrary(t),
+	}
+	plan, err := GeneratePlan(req)
+	if err != nil {
+		t.Fatalf("GeneratePlan returned an error: %v", err)
+	}
+	if plan.Duration != 73 || plan.StartOffset != 3 || plan.WeekCount() != 10 {
+		t.Errorf("Wanted 3 rest days and 10 whole weeks, got %v days, offset %v", plan.Duration, plan.StartOffset)
+	}
+	// the last week ends on race day, without a workout
+	last := plan.DaysOfWeek(10)
+	if len(last) == 0 || last[0].Order < 67 || last[len(last)-1].Order >= 73 {
+		t.Errorf("Wrong days in the last week %+v", last)
+	}
+	if len(plan.DaysByWeek()) != 10 || len(plan.DaysOfWeek(1)) == 0 || plan.DaysOfWeek(1)[0].Order <= 3 {
+		t.Errorf("Wrong days in the first week")
+	}
+	if len(plan.Weeks) != 10 || plan.Weeks[3].Name != "Week 4 - Base, recovery week" || !plan.Weeks[3].Recovery {
+		t.Errorf("Wrong weeks %+v", plan.Weeks)
+	}
+	phase, ok := plan.Phase(10)
+	if !ok || phase.Name != PhaseTaper {
+		t.Errorf("Wanted week 10 in the taper phase, got %+v", phase)
+	}
+}
@@ -0,0 +1,219 @@
+package goworkouts
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tormoder/fit"
+)
+
+// Run go test -run Golden -update to write the golden files after a change
+// of the output
+var update = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+const goldenDir = "testdata/golden"
+
+// diffLines returns a line diff of want and got, with the lines only in want
+// marked - and the lines only in got marked +
+func diffLines(want string, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&out, "%4d - %s\n", i+1, a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "%4d + %s\n", j+1, b[j])
+			j++
+		}
+	}
+	return out.String()
+}
+
+// checkGolden compares got with the golden file name, or writes it with
+// -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join(goldenDir, name)
+	if *update {
+		err := os.MkdirAll(goldenDir, 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, got, 0644)
+		}
+		if err != nil {
+			t.Fatalf("Could not write golden file: %v", err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read golden file (run go test -update to create it): %v", err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("Output differs from %v:\n%s", path, diffLines(string(want), string(got)))
+	}
+}
+
+// checkGoldenJSON compares JSON with a golden file, indented so the diff
+// shows the changed values
+func checkGoldenJSON(t *testing.T, name string, got []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	err := json.Indent(&buf, got, "", "  ")
+	if err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	buf.WriteString("\n")
+	checkGolden(t, name, buf.Bytes())
+}
+
+// goldenName returns the base name of the golden files of a FIT file, like
+// fitsdk_WorkoutRepeatSteps
+func goldenName(path string) string {
+	rel := strings.TrimPrefix(filepath.ToSlash(path), "testdata/")
+	return strings.ReplaceAll(strings.TrimSuffix(rel, ".fit"), "/", "_")
+}
+
+// isWorkoutFile returns true for FIT files the fit package reads as workout
+func isWorkoutFile(path string) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	f, err := fit.Decode(bytes.NewReader(data))
+	return err == nil && f.FileId.Type == fit.FileTypeWorkout
+}
+
+// checkSameSteps compares the steps of two workouts
+func checkSameSteps(t *testing.T, what string, want Workout, got Workout) {
+	t.Helper()
+	if len(got.Steps) != len(want.Steps) {
+		t.Errorf("%v: got %d steps, wanted %d", what, len(got.Steps), len(want.Steps))
+		return
+	}
+	for i := range want.Steps {
+		if !reflect.DeepEqual(got.Steps[i], want.Steps[i]) {
+			t.Errorf("%v: step %d differs\nGot:  %+v\nWant: %+v", what, i, got.Steps[i], want.Steps[i])
+		}
+	}
+}
+
+// TestGoldenRoundTrip runs every FIT workout in testdata through
+// FIT -> Workout -> JSON, YAML and intervals text -> Workout -> FIT. There is
+// no parser for the intervals text, so it is only compared with its golden
+// file.
+func TestGoldenRoundTrip(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.fit")
+	if err != nil {
+		t.Fatalf("Could not list testdata")
+	}
+	sdk, err := filepath.Glob("testdata/fitsdk/*.fit")
+	if err != nil {
+		t.Fatalf("Could not list testdata")
+	}
+	paths = append(paths, sdk...)
+
+	tested := 0
+	for _, path := range paths {
+		if !isWorkoutFile(path) {
+			continue
+		}
+		tested++
+		name := goldenName(path)
+		t.Run(name, func(t *testing.T) {
+			w, err := ReadFit(path)
+			if err != nil {
+				t.Fatalf("ReadFit returned an error: %v", err)
+			}
+
+			wjson, err := w.ToJSON()
+			if err != nil {
+				t.Fatalf("ToJSON returned an error: %v", err)
+			}
+			checkGoldenJSON(t, name+".json", wjson)
+			fromJSON, err := FromJSON(string(wjson))
+			if err != nil {
+				t.Fatalf("FromJSON returned an error: %v", err)
+			}
+			if !reflect.DeepEqual(fromJSON, w) {
+				t.Errorf("JSON round trip changed the workout")
+			}
+
+			wyaml, err := w.ToYAML()
+			if err != nil {
+				t.Fatalf("ToYAML returned an error: %v", err)
+			}
+			checkGolden(t, name+".yaml", wyaml)
+			fromYAML, err := FromYAML(string(wyaml))
+			if err != nil {
+				t.Fatalf("FromYAML returned an error: %v", err)
+			}
+			if !reflect.DeepEqual(fromYAML, w) {
+				t.Errorf("YAML round trip changed the workout")
+			}
+
+			intervals, err := w.ToIntervals()
+			if err != nil {
+				intervals = "error: " + err.Error()
+			}
+			checkGolden(t, name+".intervals.txt", []byte(intervals))
+
+			data, err := fromJSON.MarshalFIT(FITOptions{Reproducible: true})
+			if err != nil {
+				t.Fatalf("MarshalFIT returned an error: %v", err)
+			}
+			checkGolden(t, name+".fit.hex", []byte(hex.Dump(data)))
+			back, err := DecodeWorkout(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Could not decode the re-encoded FIT file: %v", err)
+			}
+			checkSameSteps(t, "re-encoded FIT", w, back)
+		})
+	}
+	if tested == 0 {
+		t.Errorf("No workout files found")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("a\nb\nc\nd", "a\nc\nx\nd")
+	want := "   2 - b\n   3 + x\n"
+	if got != want {
+		t.Errorf("Wrong diff:\n%s", got)
+	}
+	if diffLines("a\nb", "a\nb") != "" {
+		t.Errorf("Equal texts should not differ")
+	}
+}
@@ -2,12 +2,9 @@ package goworkouts
 
 import (
 	"bytes"
-	"encoding/binary"
 	"encoding/json"
-	"errors"
+	"io"
 	"io/ioutil"
-	"os"
-	"time"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -47,7 +44,8 @@ type WorkoutStep struct {
 	CustomTargetValueLow  uint32           `json:"targetValueLow" yaml:"targetValueLow"`
 	CustomTargetValueHigh uint32           `json:"targetValueHigh" yaml:"targetValueHigh"`
 	Intensity             string           `json:"intensity" yaml:"intensity"`
-	Notes                 string           `json:"description" yaml:"description"`
+	Notes                 string           `json:"notes" yaml:"notes"`
+	Extensions            *FITExtensions   `json:"fitExtensions,omitempty" yaml:"fitExtensions,omitempty"` // unknown and developer fields
 	// Type                  string           `json:"type"`
 }
 
@@ -68,6 +66,13 @@ type Workout struct {
 	Steps       []WorkoutStep `json:"steps" yaml:"steps"`
 	Sport       string        `json:"sport" yaml:"sport"`
 	Description string        `json:"description" yaml:"description"`
+	// Optional fields of the FIT workout message
+	SubSport       string  `json:"subSport,omitempty" yaml:"subSport,omitempty"`             // FIT sub sport name, like IndoorRowing
+	PoolLength     float64 `json:"poolLength,omitempty" yaml:"poolLength,omitempty"`         // in m
+	PoolLengthUnit string  `json:"poolLengthUnit,omitempty" yaml:"poolLengthUnit,omitempty"` // Metric or Statute
+	Capabilities   uint32  `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`     // FIT workout capabilities, derived from the steps if 0
+	// Extensions keeps the FIT fields and messages goworkouts does not model
+	Extensions *FITExtensions `json:"fitExtensions,omitempty" yaml:"fitExtensions,omitempty"`
 	// WorkoutID uint64         `json:"WorkoutId"`
 	// OwnerID   uint64         `json:"ownerId"`
 }
@@ -114,7 +119,7 @@ func GetStepByIndex(w Workout, idx fit.MessageIndex) (WorkoutStep, error) {
 			return step, nil
 		}
 	}
-	return WorkoutStep{}, errors.New("Step not found")
+	return WorkoutStep{}, &StepError{Index: int(idx), Field: "MessageIndex", Err: ErrStepNotFound}
 }
 
 func AddRepeats(stepslist []string, idxlist []fit.MessageIndex, idx fit.MessageIndex, nr_repeats uint32) ([]string) {
@@ -127,15 +132,15 @@ func AddRepeats(stepslist []string, idxlist []fit.MessageIndex, idx fit.MessageI
 }
 
 // Helper function for ToIntervals
+// Merges repeat markers ("3x") that follow each other, with only empty lines
+// in between, into one marker with the product of the counts
 func TransformRepeats(input string) string {
 	lines := strings.Split(input, "\n")
 	re := regexp.MustCompile(`^(\d+)x$`)
 	i := 0
 	var result []string
-	
+
 	for i < len(lines) {
-		stopped := false
-		multiplier := 1
 		currentLine := strings.TrimSpace(lines[i])
 		// Preserve empty lines but skip them for merging logic
 		if currentLine == "" {
@@ -145,49 +150,39 @@ func TransformRepeats(input string) string {
 		}
 		currentMatch := re.FindStringSubmatch(currentLine)
 		if currentMatch == nil {
-			result = append(result, strings.TrimSpace(lines[i]))
+			result = append(result, currentLine)
 			i++
 			continue
 		}
-		// Still here, we have a match. Need to update multiplier and scan next line(s)
-		multiplier, _ = strconv.Atoi(currentMatch[1])
+		// Still here, we have a match. Multiply with the repeat markers on
+		// the next non-empty lines
+		multiplier, _ := strconv.Atoi(currentMatch[1])
+		i++
 		for {
-			if i+1 < len(lines) {
-				// loop until non-empty line found
-				nextLine := ""
-				for {
-					nextLine = strings.TrimSpace(lines[i+1])
-					if nextLine != "" {
-						break
-					}
-					i++
-					if i+1 >= len(lines) {
-						break
-					}
-				}
-				// check if it matches a repeat step
-				nextMatch := re.FindStringSubmatch(nextLine)
-				if nextMatch == nil {
-					result = append(result, fmt.Sprintf("\n%dx", multiplier))
-					result = append(result, strings.TrimSpace(nextLine))
-					i += 2
-					multiplier = 1
-					stopped = true
-				}
-				if nextMatch != nil { // found a multiplier
-					n, _ := strconv.Atoi(nextMatch[1])
-					multiplier *= n
-					i++
-				}
-				if stopped {
-					multiplier = 1
-					break
-				}
+			for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+				i++
+			}
+			if i == len(lines) {
+				break
 			}
+			nextMatch := re.FindStringSubmatch(strings.TrimSpace(lines[i]))
+			if nextMatch == nil {
+				break
+			}
+			n, _ := strconv.Atoi(nextMatch[1])
+			multiplier *= n
+			i++
+		}
+		result = append(result, fmt.Sprintf("\n%dx", multiplier))
+		// the line the repeats apply to, or an empty line at the end
+		if i < len(lines) {
+			result = append(result, strings.TrimSpace(lines[i]))
+			i++
+		} else {
+			result = append(result, "")
 		}
-		//i++
 	}
-			
+
 	return strings.Join(result, "\n")
 }
 
@@ -307,7 +302,10 @@ type TrainingPlan struct {
 	Name         string        `json:"name" yaml:"name"`
 	TrainingDays []TrainingDay `json:"trainingDays" yaml:"trainingDays"`
 	Duration     uint32        `json:"duration" yaml:"duration"` // in number of calendar days
+	StartOffset  uint32        `json:"startOffset,omitempty" yaml:"startOffset,omitempty"` // rest days before week 1
 	Description  string        `json:"description" yaml:"description"`
+	Weeks        []PlanWeek    `json:"weeks,omitempty" yaml:"weeks,omitempty"`   // optional
+	Phases       []PlanPhase   `json:"phases,omitempty" yaml:"phases,omitempty"` // optional
 }
 
 // NewTrainingPlan type for training plans that do not exist yet
@@ -316,7 +314,10 @@ type NewTrainingPlan struct {
 	Name         string        `json:"name" yaml:"name"`
 	TrainingDays []TrainingDay `json:"trainingDays" yaml:"trainingDays"`
 	Duration     uint32        `json:"duration" yaml:"duration"` // in number of calendar days
+	StartOffset  uint32        `json:"startOffset,omitempty" yaml:"startOffset,omitempty"` // rest days before week 1
 	Description  string        `json:"description" yaml:"description"`
+	Weeks        []PlanWeek    `json:"weeks,omitempty" yaml:"weeks,omitempty"`   // optional
+	Phases       []PlanPhase   `json:"phases,omitempty" yaml:"phases,omitempty"` // optional
 }
 
 // ListOfPlans storing list of plans
@@ -339,6 +340,7 @@ var targetTypes = map[string]fit.WktStepTarget{
 	"SwimStroke":   fit.WktStepTargetSwimStroke,   //  WktStepTarget = 11
 	"SpeedLap":     fit.WktStepTargetSpeedLap,     // WktStepTarget = 12
 	"HeartRateLap": fit.WktStepTargetHeartRateLap, // WktStepTarget = 13
+	"Invalid":      fit.WktStepTargetInvalid,      // WktStepTarget = 0xFF
 }
 
 var intensityTypes = map[string]fit.Intensity{
@@ -349,7 +351,7 @@ var intensityTypes = map[string]fit.Intensity{
 	"Recovery": fit.IntensityRecovery, // Intensity = 4
 	"Interval": fit.IntensityInterval, // Intensity = 5
 	"Other": fit.IntensityOther, // Intensity = 6
-	//IntensityInvalid  Intensity = 0xFF
+	"Invalid": fit.IntensityInvalid, // Intensity = 0xFF
 }
 
 var durationTypes = map[string]fit.WktStepDuration{
@@ -384,55 +386,14 @@ var durationTypes = map[string]fit.WktStepDuration{
 	"RepetitionTime":                     fit.WktStepDurationRepetitionTime,                  // WktStepDuration = 28
 	"Reps":                               fit.WktStepDurationReps,                            // WktStepDuration = 29
 	"TimeOnly":                           fit.WktStepDurationTimeOnly,                        // WktStepDuration = 31
-	//"Invalid":                            31,                               // WktStepDuration = 0xFF
+	"Invalid":                            fit.WktStepDurationInvalid,                         // WktStepDuration = 0xFF
 }
 
-// ToFIT exports to FIT
-// only creates empty FIT object for now
+// ToFIT exports to FIT, with the default FITOptions. The description and FIT
+// extensions of the workout are lost, use WriteWorkout or MarshalFIT to keep
+// them.
 func (w *Workout) ToFIT() (*fit.File, error) {
-	h := fit.NewHeader(fit.V10, true)
-
-	workoutmsg := fit.NewWorkoutMsg()
-	workoutmsg.WktName = w.Name
-	workoutmsg.Sport = sportMapping[w.Sport]
-
-	WorkoutSteps := []*fit.WorkoutStepMsg{}
-
-	for _, step := range w.Steps {
-		newmsg := fit.NewWorkoutStepMsg()
-
-		newmsg.MessageIndex = step.MessageIndex
-		newmsg.WktStepName = step.WktStepName
-		newmsg.DurationType = durationTypes[step.DurationType]
-		newmsg.DurationValue = step.DurationValue
-		newmsg.Intensity = intensityTypes[step.Intensity]
-		newmsg.Notes = step.Notes
-		newmsg.TargetType = targetTypes[step.TargetType]
-		newmsg.TargetValue = step.TargetValue
-		newmsg.CustomTargetValueLow = step.CustomTargetValueLow
-		newmsg.CustomTargetValueHigh = step.CustomTargetValueHigh
-		WorkoutSteps = append(WorkoutSteps, newmsg)
-	}
-
-	workoutFile := fit.WorkoutFile{}
-	workoutFile.Workout = workoutmsg
-	workoutFile.WorkoutSteps = WorkoutSteps
-
-	newFile, err := fit.NewFile(fit.FileTypeWorkout, h)
-	if err != nil {
-		return newFile, err
-	}
-
-	newFile.FileId.TimeCreated = time.Now()
-	newFile.FileId.Manufacturer = fit.ManufacturerGarmin
-	newFileWorkoutFile, err := newFile.Workout()
-	if err != nil {
-		return newFile, err
-	}
-
-	*newFileWorkoutFile = workoutFile
-
-	return newFile, nil
+	return w.ToFITWithOptions(FITOptions{})
 }
 
 // MaxUint maximum int value (default in fit)
@@ -462,30 +423,15 @@ func makeStep(s *fit.WorkoutStepMsg) (WorkoutStep, error) {
 	return step, nil
 }
 
-func exists(name string) bool {
-	if _, err := os.Stat(name); err != nil {
-		if os.IsNotExist(err) {
-			return false
-		}
-	}
-	return true
-}
-
-// WriteFit writes FIT file from Workout
+// WriteFit writes FIT file from Workout. The file is replaced atomically if
+// overwrite is true, see WriteFile. A fit.File has no description or FIT
+// extensions, use WriteWorkout to write a Workout with them.
 func WriteFit(f string, w *fit.File, overwrite bool) (ok bool, err error) {
-	if exists(f) && !overwrite {
-		err := errors.New("File exists and overwrite was set to false")
-		return false, err
+	opts := WriteOptions{Overwrite: OverwriteFail}
+	if overwrite {
+		opts.Overwrite = OverwriteReplace
 	}
-	fitFile, err := os.OpenFile(f, os.O_WRONLY|os.O_CREATE, 0777)
-	if err != nil {
-		return false, err
-	}
-	defer fitFile.Close()
-
-	bo := binary.LittleEndian
-
-	err = fit.Encode(fitFile, w, bo)
+	_, err = WriteFitWithOptions(f, w, opts)
 	if err != nil {
 		return false, err
 	}
@@ -500,15 +446,31 @@ func ReadFit(f string) (Workout, error) {
 		return Workout{}, err
 	}
 
-	fitf, err := fit.Decode(bytes.NewReader(data))
+	neww, err := DecodeWorkout(bytes.NewReader(data))
 	if err != nil {
 		return Workout{}, err
 	}
+	neww.Filename = f
+
+	return neww, nil
+}
+
+// DecodeWorkout reads a Workout from a FIT workout file
+func DecodeWorkout(r io.Reader) (Workout, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Workout{}, err
+	}
+
+	fitf, err := fit.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Workout{}, fmt.Errorf("%w: %w", ErrInvalidFIT, err)
+	}
 
 	fittype := fitf.FileId.Type
 
 	if fittype != fit.FileTypeWorkout {
-		return Workout{}, errors.New("We only accept fit files of type Workout")
+		return Workout{}, ErrNotWorkoutFile{Got: fittype}
 	}
 
 	w, err := fitf.Workout()
@@ -522,22 +484,27 @@ func ReadFit(f string) (Workout, error) {
 	}
 
 	neww := Workout{}
-	neww.Name = w.Workout.WktName
-	// use sportMapping to get the sport name
-	for k, v := range sportMapping {
-		if v == w.Workout.Sport {
-			neww.Sport = k
-		}
+	if w.Workout != nil {
+		neww.readWorkoutMsg(w.Workout)
 	}
+	raw, err := parseRawFIT(data)
+	if err != nil {
+		return Workout{}, err
+	}
+	neww.Description = readDescription(raw)
+	extensions, stepExtensions := readExtensions(raw)
+	neww.Extensions = extensions
 
-	neww.Filename = f
 	var newsteps []WorkoutStep
 
-	for _, step := range steps {
+	for i, step := range steps {
 		s, err := makeStep(step)
 		if err != nil {
 			return Workout{}, err
 		}
+		if i < len(stepExtensions) {
+			s.Extensions = stepExtensions[i]
+		}
 		newsteps = append(newsteps, s)
 	}
 
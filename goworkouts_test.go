@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"testing"
 	"strings"
 
@@ -142,12 +143,10 @@ func TestReadFittoJSON(t *testing.T) {
 		t.Errorf("ReadFit returned an error")
 	}
 	wjson, err := w.ToJSON()
-	if len(wjson) != 934 {
-		t.Errorf("ToJSON returned a string of a different length")
-	}
 	if err != nil {
 		t.Errorf("ToJSON returned an error")
 	}
+	checkGoldenJSON(t, "fitsdk_WorkoutCustomTargetValues.json", wjson)
 }
 
 func TestReadFittoIntervals(t *testing.T) {
@@ -208,14 +207,10 @@ func TestReadFittoYAML(t *testing.T) {
 		t.Errorf("ReadFit returned an error")
 	}
 	wyaml, err := w.ToYAML()
-	//fmt.Println(len(wyaml))
-	//fmt.Println(string(wyaml))
-	if len(wyaml) != 922 {
-		t.Errorf("ToYAML returned a string of a different length")
-	}
 	if err != nil {
 		t.Errorf("ToYAML returned an error")
 	}
+	checkGolden(t, "fitsdk_WorkoutCustomTargetValues.yaml", wyaml)
 }
 
 func TestReadFittoFIT(t *testing.T) {
@@ -227,14 +222,15 @@ func TestReadFittoFIT(t *testing.T) {
 	if err != nil {
 		t.Errorf("ToFit returned an error")
 	}
-	ok, err := WriteFit("testdata/new.fit", wjfit, true)
+	newfit := filepath.Join(t.TempDir(), "new.fit")
+	ok, err := WriteFit(newfit, wjfit, true)
 	if err != nil {
 		t.Errorf("Error writing file")
 	}
 	if !ok {
 		t.Errorf("Not written")
 	}
-	data, _ := ioutil.ReadFile("testdata/new.fit")
+	data, _ := ioutil.ReadFile(newfit)
 	_, err = fit.Decode(bytes.NewReader(data))
 	if err != nil {
 		t.Errorf("Could not read written file")
@@ -252,14 +248,15 @@ func TestWriter(t *testing.T) {
 
 	oldSteps := oldWorkout.WorkoutSteps
 
-	ok, err := WriteFit("testdata/new.fit", fitf, true)
+	newfit := filepath.Join(t.TempDir(), "new.fit")
+	ok, err := WriteFit(newfit, fitf, true)
 	if err != nil {
 		t.Errorf("Error writing file")
 	}
 	if !ok {
 		t.Errorf("Not written")
 	}
-	data, _ = ioutil.ReadFile("testdata/new.fit")
+	data, _ = ioutil.ReadFile(newfit)
 	fitf, err = fit.Decode(bytes.NewReader(data))
 	if err != nil {
 		t.Errorf("Could not read written file")
@@ -308,16 +305,11 @@ func TestTrainingPlan(t *testing.T) {
 
 	listofdays := []TrainingDay{day1, day2, day3}
 
-	plan := TrainingPlan{uuid.New(), "", "Test Plan", listofdays, 4, "Description"}
+	plan := TrainingPlan{ID: uuid.MustParse("6f6e0ae4-54f4-4d0c-9a35-2f4b3a1f1e7d"), Name: "Test Plan", TrainingDays: listofdays, Duration: 4, Description: "Description"}
 	planJSON, err := json.MarshalIndent(plan, "", "   ")
 	if err != nil {
 		t.Errorf("Could not convert training plan to json")
 	}
-	//	fmt.Println(string(planJSON))
-	//	fmt.Println(len(planJSON))
-	expected := 7674
-	if len(planJSON) != expected {
-		t.Errorf("Conversion of the training plan to JSON gave the wrong json length. Expected %v, got %v", expected, len(planJSON))
-	}
+	checkGolden(t, "plan.json", planJSON)
 }
 
@@ -0,0 +1,329 @@
+// Package intervalsicu is a client for the intervals.icu API. It uploads
+// goworkouts workouts to the workout library of an athlete, pushes training
+// plans to the calendar and reads library workouts back.
+package intervalsicu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanderroosendaal/goworkouts"
+)
+
+// DefaultBaseURL is the URL of the intervals.icu API
+const DefaultBaseURL = "https://intervals.icu/api/v1"
+
+// CurrentAthlete is the athlete ID of the owner of the API key
+const CurrentAthlete = "0"
+
+// sportTypes maps goworkouts sport names to intervals.icu activity types
+var sportTypes = map[string]string{
+	"generic":         "Workout",
+	"running":         "Run",
+	"cycling":         "Ride",
+	"swimming":        "Swim",
+	"walking":         "Walk",
+	"crosscountryski": "NordicSki",
+	"rowing":          "Rowing",
+	"hiking":          "Hike",
+	"inlineskate":     "InlineSkate",
+	"iceskate":        "IceSkate",
+	"hitt":            "HighIntensityIntervalTraining",
+}
+
+// sportType returns the intervals.icu activity type of a sport
+func sportType(sport string) string {
+	if t, ok := sportTypes[sport]; ok {
+		return t
+	}
+	return "Workout"
+}
+
+// sportName returns the goworkouts sport of an intervals.icu activity type
+func sportName(activityType string) string {
+	for sport, t := range sportTypes {
+		if t == activityType && sport != "generic" {
+			return sport
+		}
+	}
+	return "generic"
+}
+
+// APIError is returned for a response with an error status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("intervals.icu returned %d: %v", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// Client calls the intervals.icu API for one athlete
+type Client struct {
+	BaseURL   string // defaults to DefaultBaseURL
+	APIKey    string // from the developer settings of the athlete
+	AthleteID string // like i12345, or CurrentAthlete
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// MaxRetries is how many times a request is retried after a rate limit,
+	// or after a server error if repeating the request is safe
+	MaxRetries int
+	// MaxBackoff limits the wait between retries, including waits asked
+	// for by the server
+	MaxBackoff time.Duration
+
+	// sleep waits between retries, it is replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient returns a client for the athlete, with 3 retries
+func NewClient(apiKey string, athleteID string) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		APIKey:     apiKey,
+		AthleteID:  athleteID,
+		MaxRetries: 3,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// wait sleeps for d or until ctx is done
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// maxBackoffShift limits the exponential backoff to 2^10 seconds, so it does
+// not overflow for many retries
+const maxBackoffShift = 10
+
+// backoff returns how long to wait before retry attempt of a request that
+// got resp
+func (c *Client) backoff(resp *http.Response, attempt int) time.Duration {
+	if attempt > maxBackoffShift {
+		attempt = maxBackoffShift
+	}
+	d := time.Second << uint(attempt)
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		d = time.Duration(seconds) * time.Second
+	}
+	if c.MaxBackoff > 0 && d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	return d
+}
+
+// idempotent returns true for requests that can be repeated without creating
+// something twice: reads and upserts
+func idempotent(method string, path string) bool {
+	return method == http.MethodGet || strings.Contains(path, "upsert=true")
+}
+
+// retry returns true for responses worth another try. A server error may
+// come after the request was carried out, so only idempotent requests are
+// retried after one.
+func retry(status int, idempotent bool) bool {
+	return status == http.StatusTooManyRequests || (status >= 500 && idempotent)
+}
+
+// do sends a request with body encoded as JSON to the path below the
+// athlete and decodes the JSON response into out. Rate limited requests, and
+// idempotent requests that failed on the server, are retried.
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	athlete := c.AthleteID
+	if athlete == "" {
+		athlete = CurrentAthlete
+	}
+	url := fmt.Sprintf("%v/athlete/%v%v", strings.TrimSuffix(base, "/"), athlete, path)
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	sleep := c.sleep
+	if sleep == nil {
+		sleep = wait
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth("API_KEY", c.APIKey)
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 10<<20))
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if out == nil || len(respBody) == 0 {
+				return nil
+			}
+			return json.Unmarshal(respBody, out)
+		}
+		if !retry(resp.StatusCode, idempotent(method, path)) || attempt >= c.MaxRetries {
+			return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		}
+		err = sleep(ctx, c.backoff(resp, attempt))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// LibraryWorkout is a workout in the library of an athlete
+type LibraryWorkout struct {
+	ID          int         `json:"id,omitempty"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Type        string      `json:"type,omitempty"`
+	FolderID    int         `json:"folder_id,omitempty"`
+	WorkoutDoc  *WorkoutDoc `json:"workout_doc,omitempty"`
+}
+
+// Event is an event on the calendar of an athlete
+type Event struct {
+	ID             int    `json:"id,omitempty"`
+	Category       string `json:"category"`
+	StartDateLocal string `json:"start_date_local"`
+	Name           string `json:"name"`
+	Description    string `json:"description,omitempty"`
+	Type           string `json:"type,omitempty"`
+	ExternalID     string `json:"external_id,omitempty"`
+}
+
+// description returns the description of a workout for intervals.icu: the
+// description of the workout followed by its steps as intervals text, which
+// intervals.icu parses into the structured workout
+func description(w *goworkouts.Workout) (string, error) {
+	steps, err := w.ToIntervals()
+	if err != nil {
+		return "", err
+	}
+	if w.Description == "" {
+		return steps, nil
+	}
+	return w.Description + "\n\n" + steps, nil
+}
+
+// UploadWorkout adds a workout to the library, in the folder with ID
+// folderID or outside any folder if folderID is 0. Use
+// goworkouts.Fidelity(w, "intervals") to find out what the upload loses.
+func (c *Client) UploadWorkout(ctx context.Context, w *goworkouts.Workout, folderID int) (LibraryWorkout, error) {
+	desc, err := description(w)
+	if err != nil {
+		return LibraryWorkout{}, err
+	}
+	in := LibraryWorkout{
+		Name:        w.Name,
+		Description: desc,
+		Type:        sportType(w.Sport),
+		FolderID:    folderID,
+	}
+	var out LibraryWorkout
+	err = c.do(ctx, http.MethodPost, "/workouts", in, &out)
+	return out, err
+}
+
+// ListWorkouts returns the workouts in the library
+func (c *Client) ListWorkouts(ctx context.Context) ([]LibraryWorkout, error) {
+	var out []LibraryWorkout
+	err := c.do(ctx, http.MethodGet, "/workouts", nil, &out)
+	return out, err
+}
+
+// Workouts returns the workouts in the library as goworkouts workouts.
+// Workouts without steps are left out.
+func (c *Client) Workouts(ctx context.Context) ([]goworkouts.Workout, error) {
+	library, err := c.ListWorkouts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var workouts []goworkouts.Workout
+	for _, lw := range library {
+		if lw.WorkoutDoc == nil || len(lw.WorkoutDoc.Steps) == 0 {
+			continue
+		}
+		w, err := lw.Workout()
+		if err != nil {
+			return nil, fmt.Errorf("Workout %q: %w", lw.Name, err)
+		}
+		workouts = append(workouts, w)
+	}
+	return workouts, nil
+}
+
+// PlanEvents returns the calendar events of a plan with day 1 on start. The
+// external IDs are made from the plan ID, day and position, so pushing the
+// plan again updates the events.
+func PlanEvents(p *goworkouts.TrainingPlan, start time.Time) ([]Event, error) {
+	var events []Event
+	day1 := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	for _, day := range p.TrainingDays {
+		date := day1.AddDate(0, 0, int(day.Order)-1)
+		for j := range day.Workouts {
+			w := &day.Workouts[j]
+			desc, err := description(w)
+			if err != nil {
+				return nil, fmt.Errorf("Day %d, workout %d: %w", day.Order, j, err)
+			}
+			events = append(events, Event{
+				Category:       "WORKOUT",
+				StartDateLocal: date.Format("2006-01-02T15:04:05"),
+				Name:           w.Name,
+				Description:    desc,
+				Type:           sportType(w.Sport),
+				ExternalID:     fmt.Sprintf("%v-%d-%d", p.ID, day.Order, j),
+			})
+		}
+	}
+	return events, nil
+}
+
+// PushPlan puts the workouts of a plan on the calendar, with day 1 of the
+// plan on the date of start. Events pushed before for the same plan are
+// updated.
+func (c *Client) PushPlan(ctx context.Context, p *goworkouts.TrainingPlan, start time.Time) ([]Event, error) {
+	events, err := PlanEvents(p, start)
+	if err != nil {
+		return nil, err
+	}
+	var out []Event
+	err = c.do(ctx, http.MethodPost, "/events/bulk?upsert=true", events, &out)
+	return out, err
+}
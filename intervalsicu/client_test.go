@@ -0,0 +1,290 @@
+package intervalsicu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sanderroosendaal/goworkouts"
+)
+
+// stubServer is an httptest stand-in for intervals.icu
+type stubServer struct {
+	t        *testing.T
+	limited  int // number of requests to answer with 429 first
+	failing  int // number of requests to answer with 502 after handling them
+	requests []*http.Request
+	bodies   [][]byte
+	library  []LibraryWorkout
+}
+
+func (s *stubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || user != "API_KEY" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body []byte
+	if r.Body != nil {
+		var raw json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&raw)
+		body = raw
+	}
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	if s.limited > 0 {
+		s.limited--
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	if s.failing > 0 {
+		// a gateway error after the request was carried out
+		s.failing--
+		s.handle(httptest.NewRecorder(), r, body)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	s.handle(w, r, body)
+}
+
+// handle answers a request to the API
+func (s *stubServer) handle(w http.ResponseWriter, r *http.Request, body []byte) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/athlete/i42/workouts":
+		var lw LibraryWorkout
+		_ = json.Unmarshal(body, &lw)
+		lw.ID = 100 + len(s.library)
+		s.library = append(s.library, lw)
+		_ = json.NewEncoder(w).Encode(lw)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/athlete/i42/workouts":
+		_ = json.NewEncoder(w).Encode(s.library)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/athlete/i42/events/bulk":
+		if r.URL.Query().Get("upsert") != "true" {
+			s.t.Errorf("Events are not upserted")
+		}
+		var events []Event
+		_ = json.Unmarshal(body, &events)
+		for i := range events {
+			events[i].ID = i + 1
+		}
+		_ = json.NewEncoder(w).Encode(events)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"not found"}`))
+	}
+}
+
+// newTestClient returns a client for a stub server, which records the
+// waits instead of sleeping
+func newTestClient(t *testing.T, s *stubServer) (*Client, *[]time.Duration) {
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	c := NewClient("secret", "i42")
+	c.BaseURL = server.URL + "/api/v1"
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return c, &waits
+}
+
+func readWorkout(t *testing.T, path string) goworkouts.Workout {
+	t.Helper()
+	w, err := goworkouts.ReadFit(path)
+	if err != nil {
+		t.Fatalf("ReadFit returned an error: %v", err)
+	}
+	return w
+}
+
+func TestUploadWorkout(t *testing.T) {
+	s := &stubServer{t: t}
+	c, _ := newTestClient(t, s)
+	w := readWorkout(t, "../testdata/repeats.fit")
+	w.Description = "Threshold session"
+
+	lw, err := c.UploadWorkout(context.Background(), &w, 5)
+	if err != nil {
+		t.Fatalf("UploadWorkout returned an error: %v", err)
+	}
+	if lw.ID != 100 || lw.FolderID != 5 || lw.Name != w.Name {
+		t.Errorf("Wrong library workout %+v", lw)
+	}
+	steps, _ := w.ToIntervals()
+	if lw.Description != "Threshold session\n\n"+steps {
+		t.Errorf("Wrong description %q", lw.Description)
+	}
+	if lw.Type != sportType(w.Sport) {
+		t.Errorf("Got type %v for sport %v", lw.Type, w.Sport)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	s := &stubServer{t: t, limited: 2}
+	c, waits := newTestClient(t, s)
+	c.MaxBackoff = 5 * time.Second
+
+	_, err := c.ListWorkouts(context.Background())
+	if err != nil {
+		t.Fatalf("ListWorkouts returned an error: %v", err)
+	}
+	if len(s.requests) != 3 {
+		t.Errorf("Got %d requests, wanted 3", len(s.requests))
+	}
+	if len(*waits) != 2 || (*waits)[0] != 5*time.Second {
+		t.Errorf("Got waits %v, wanted two of 5s", *waits)
+	}
+
+	s.limited = 10
+	_, err = c.ListWorkouts(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Got %v, wanted a rate limit error", err)
+	}
+
+	c.APIKey = "wrong"
+	_, err = c.ListWorkouts(context.Background())
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Got %v, wanted an authorization error", err)
+	}
+}
+
+func TestRetryServerError(t *testing.T) {
+	s := &stubServer{t: t, failing: 1}
+	c, waits := newTestClient(t, s)
+	w := readWorkout(t, "../testdata/repeats.fit")
+
+	// creating a workout is not repeated, it may have been stored
+	_, err := c.UploadWorkout(context.Background(), &w, 0)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Got %v, wanted a gateway error", err)
+	}
+	if len(s.requests) != 1 || len(s.library) != 1 {
+		t.Errorf("Got %d requests and %d workouts, wanted 1 of each", len(s.requests), len(s.library))
+	}
+
+	// reads and upserts are
+	s.failing = 1
+	library, err := c.ListWorkouts(context.Background())
+	if err != nil || len(library) != 1 {
+		t.Errorf("ListWorkouts returned %v, %v", library, err)
+	}
+	s.failing = 1
+	plan := goworkouts.TrainingPlan{TrainingDays: []goworkouts.TrainingDay{{Order: 1, Workouts: []goworkouts.Workout{w}}}}
+	_, err = c.PushPlan(context.Background(), &plan, time.Now())
+	if err != nil {
+		t.Errorf("PushPlan returned an error: %v", err)
+	}
+	if len(s.requests) != 5 || len(*waits) != 2 {
+		t.Errorf("Got %d requests and %d waits, wanted 5 and 2", len(s.requests), len(*waits))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{}
+	resp := &http.Response{Header: http.Header{}}
+	if d := c.backoff(resp, 2); d != 4*time.Second {
+		t.Errorf("Got %v, wanted 4s", d)
+	}
+	for _, attempt := range []int{40, 64, 1000} {
+		if d := c.backoff(resp, attempt); d != time.Second<<maxBackoffShift {
+			t.Errorf("Attempt %d: got %v, wanted %v", attempt, d, time.Second<<maxBackoffShift)
+		}
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	s := &stubServer{t: t, limited: 1}
+	c, _ := newTestClient(t, s)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return wait(ctx, d)
+	}
+	_, err := c.ListWorkouts(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Got %v, wanted context.Canceled", err)
+	}
+}
+
+func TestPushPlan(t *testing.T) {
+	s := &stubServer{t: t}
+	c, _ := newTestClient(t, s)
+	w := readWorkout(t, "../testdata/repeats.fit")
+	plan := goworkouts.TrainingPlan{
+		Name: "Plan",
+		TrainingDays: []goworkouts.TrainingDay{
+			{Order: 1, Workouts: []goworkouts.Workout{w}},
+			{Order: 3, Workouts: []goworkouts.Workout{w, w}},
+		},
+	}
+	start := time.Date(2024, 4, 29, 18, 30, 0, 0, time.Local)
+
+	events, err := c.PushPlan(context.Background(), &plan, start)
+	if err != nil {
+		t.Fatalf("PushPlan returned an error: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Got %d events, wanted 3", len(events))
+	}
+	dates := []string{"2024-04-29T00:00:00", "2024-05-01T00:00:00", "2024-05-01T00:00:00"}
+	for i, event := range events {
+		if event.StartDateLocal != dates[i] || event.Category != "WORKOUT" || event.ID != i+1 {
+			t.Errorf("Wrong event %d: %+v", i, event)
+		}
+	}
+	if events[1].ExternalID == events[2].ExternalID {
+		t.Errorf("Events of the same day have the same external ID")
+	}
+}
+
+func TestWorkouts(t *testing.T) {
+	s := &stubServer{t: t}
+	s.library = []LibraryWorkout{
+		{ID: 1, Name: "Notes only", Description: "Just ride"},
+		{
+			ID:          2,
+			Name:        "Intervals",
+			Type:        "Rowing",
+			Description: "Hard\n\n3x\n- 500m 90-95%",
+			WorkoutDoc: &WorkoutDoc{Steps: []DocStep{
+				{Reps: 3, Steps: []DocStep{{Distance: 500, Power: &DocTarget{Units: "%ftp", Start: 90, End: 95}}}},
+			}},
+		},
+	}
+	c, _ := newTestClient(t, s)
+	workouts, err := c.Workouts(context.Background())
+	if err != nil {
+		t.Fatalf("Workouts returned an error: %v", err)
+	}
+	if len(workouts) != 1 {
+		t.Fatalf("Got %d workouts, wanted 1", len(workouts))
+	}
+	w := workouts[0]
+	if w.Name != "Intervals" || w.Sport != "rowing" || w.Description != "Hard" || len(w.Steps) != 2 {
+		t.Errorf("Wrong workout %+v", w)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	s := &stubServer{t: t}
+	c, _ := newTestClient(t, s)
+	c.AthleteID = "i43"
+	_, err := c.ListWorkouts(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got %v, wanted a not found error", err)
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		t.Errorf("The error does not have the response: %v", err)
+	}
+}
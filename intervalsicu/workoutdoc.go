@@ -0,0 +1,161 @@
+package intervalsicu
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/sanderroosendaal/goworkouts"
+	"github.com/tormoder/fit"
+)
+
+// WorkoutDoc is the structured workout intervals.icu makes from the
+// description of a workout
+type WorkoutDoc struct {
+	Duration float64   `json:"duration,omitempty"` // in s
+	Distance float64   `json:"distance,omitempty"` // in m
+	Steps    []DocStep `json:"steps"`
+}
+
+// DocStep is a step of a WorkoutDoc. A step with Reps repeats its Steps.
+type DocStep struct {
+	Text     string     `json:"text,omitempty"`
+	Duration float64    `json:"duration,omitempty"` // in s
+	Distance float64    `json:"distance,omitempty"` // in m
+	Reps     int        `json:"reps,omitempty"`
+	Steps    []DocStep  `json:"steps,omitempty"`
+	Warmup   bool       `json:"warmup,omitempty"`
+	Cooldown bool       `json:"cooldown,omitempty"`
+	Ramp     bool       `json:"ramp,omitempty"`
+	Power    *DocTarget `json:"power,omitempty"`
+	HR       *DocTarget `json:"hr,omitempty"`
+	Cadence  *DocTarget `json:"cadence,omitempty"`
+	Pace     *DocTarget `json:"pace,omitempty"`
+}
+
+// DocTarget is a target of a DocStep, a single value or a range from Start
+// to End, in Units like %ftp, w, power_zone, %hr, bpm, hr_zone or rpm
+type DocTarget struct {
+	Units string  `json:"units"`
+	Value float64 `json:"value,omitempty"`
+	Start float64 `json:"start,omitempty"`
+	End   float64 `json:"end,omitempty"`
+}
+
+// bounds returns the low and high value of the target
+func (t *DocTarget) bounds() (uint32, uint32) {
+	if t.Start != 0 || t.End != 0 {
+		low, high := math.Min(t.Start, t.End), math.Max(t.Start, t.End)
+		return uint32(math.Round(low)), uint32(math.Round(high))
+	}
+	v := uint32(math.Round(t.Value))
+	return v, v
+}
+
+// setTarget sets the target of a FIT step. Power and heart rate use the FIT
+// conventions: percentages up to 1000 and 100, watts plus 1000 and beats per
+// minute plus 100. Targets FIT cannot express, like pace or percentages of
+// the threshold heart rate, leave the step open.
+func setTarget(step *goworkouts.WorkoutStep, s DocStep) {
+	step.TargetType = "Open"
+	switch {
+	case s.Power != nil:
+		low, high := s.Power.bounds()
+		switch s.Power.Units {
+		case "power_zone":
+			step.TargetType, step.TargetValue = "Power", low
+		case "%ftp":
+			step.TargetType, step.CustomTargetValueLow, step.CustomTargetValueHigh = "Power", low, high
+		case "w":
+			step.TargetType, step.CustomTargetValueLow, step.CustomTargetValueHigh = "Power", low+1000, high+1000
+		}
+	case s.HR != nil:
+		low, high := s.HR.bounds()
+		switch s.HR.Units {
+		case "hr_zone":
+			step.TargetType, step.TargetValue = "HeartRate", low
+		case "%hr":
+			step.TargetType, step.CustomTargetValueLow, step.CustomTargetValueHigh = "HeartRate", low, high
+		case "bpm":
+			step.TargetType, step.CustomTargetValueLow, step.CustomTargetValueHigh = "HeartRate", low+100, high+100
+		}
+	case s.Cadence != nil && s.Cadence.Units == "rpm":
+		low, high := s.Cadence.bounds()
+		step.TargetType, step.CustomTargetValueLow, step.CustomTargetValueHigh = "Cadence", low, high
+	}
+}
+
+// appendSteps appends the FIT steps of the doc steps to steps
+func appendSteps(steps []goworkouts.WorkoutStep, docSteps []DocStep) []goworkouts.WorkoutStep {
+	for _, s := range docSteps {
+		if s.Reps > 0 {
+			if len(s.Steps) == 0 {
+				continue
+			}
+			first := len(steps)
+			steps = appendSteps(steps, s.Steps)
+			steps = append(steps, goworkouts.WorkoutStep{
+				MessageIndex:  fit.MessageIndex(len(steps)),
+				DurationType:  "RepeatUntilStepsCmplt",
+				DurationValue: uint32(first),
+				TargetType:    "Open",
+				TargetValue:   uint32(s.Reps),
+				Intensity:     "Active",
+			})
+			continue
+		}
+		step := goworkouts.WorkoutStep{
+			MessageIndex: fit.MessageIndex(len(steps)),
+			DurationType: "Open",
+			Intensity:    "Active",
+			Notes:        s.Text,
+		}
+		switch {
+		case s.Duration > 0:
+			step.DurationType = "Time"
+			step.DurationValue = uint32(math.Round(s.Duration * 1000))
+		case s.Distance > 0:
+			step.DurationType = "Distance"
+			step.DurationValue = uint32(math.Round(s.Distance * 100))
+		}
+		switch {
+		case s.Warmup:
+			step.Intensity = "Warmup"
+		case s.Cooldown:
+			step.Intensity = "Cooldown"
+		}
+		setTarget(&step, s)
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// stepLine matches the lines of a description that intervals.icu reads as
+// steps or repeats, and the section headers ToIntervals writes
+var stepLine = regexp.MustCompile(`^\s*(-|\d+x\s*$|(Warmup|Cooldown)\s*$)`)
+
+// descriptionText returns the lines of a description that are not steps
+func descriptionText(description string) string {
+	var lines []string
+	for _, line := range strings.Split(description, "\n") {
+		if !stepLine.MatchString(line) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Workout returns the library workout as a goworkouts workout, made from
+// its structured steps
+func (lw *LibraryWorkout) Workout() (goworkouts.Workout, error) {
+	if lw.WorkoutDoc == nil {
+		return goworkouts.Workout{}, errors.New("Workout has no structured steps")
+	}
+	return goworkouts.Workout{
+		Name:        lw.Name,
+		Sport:       sportName(lw.Type),
+		Description: descriptionText(lw.Description),
+		Steps:       appendSteps(nil, lw.WorkoutDoc.Steps),
+	}, nil
+}
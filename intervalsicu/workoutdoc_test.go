@@ -0,0 +1,75 @@
+package intervalsicu
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sanderroosendaal/goworkouts"
+)
+
+const docJSON = `{
+  "duration": 3000,
+  "steps": [
+    {"duration": 600, "warmup": true, "ramp": true, "power": {"units": "%ftp", "start": 50, "end": 75}},
+    {"reps": 2, "steps": [
+      {"reps": 3, "steps": [
+        {"duration": 60, "power": {"units": "w", "value": 300}},
+        {"duration": 30, "text": "easy", "hr": {"units": "hr_zone", "value": 1}}
+      ]},
+      {"distance": 1000, "hr": {"units": "bpm", "start": 150, "end": 160}, "cadence": {"units": "rpm", "value": 90}}
+    ]},
+    {"duration": 300, "cooldown": true, "pace": {"units": "%pace", "value": 80}}
+  ]
+}`
+
+func TestLibraryWorkout(t *testing.T) {
+	var doc WorkoutDoc
+	err := json.Unmarshal([]byte(docJSON), &doc)
+	if err != nil {
+		t.Fatalf("Could not read the workout doc: %v", err)
+	}
+	lw := LibraryWorkout{Name: "Nested", Type: "Ride", WorkoutDoc: &doc}
+	w, err := lw.Workout()
+	if err != nil {
+		t.Fatalf("Workout returned an error: %v", err)
+	}
+	want := []goworkouts.WorkoutStep{
+		{MessageIndex: 0, DurationType: "Time", DurationValue: 600000, TargetType: "Power", CustomTargetValueLow: 50, CustomTargetValueHigh: 75, Intensity: "Warmup"},
+		{MessageIndex: 1, DurationType: "Time", DurationValue: 60000, TargetType: "Power", CustomTargetValueLow: 1300, CustomTargetValueHigh: 1300, Intensity: "Active"},
+		{MessageIndex: 2, DurationType: "Time", DurationValue: 30000, TargetType: "HeartRate", TargetValue: 1, Intensity: "Active", Notes: "easy"},
+		{MessageIndex: 3, DurationType: "RepeatUntilStepsCmplt", DurationValue: 1, TargetType: "Open", TargetValue: 3, Intensity: "Active"},
+		{MessageIndex: 4, DurationType: "Distance", DurationValue: 100000, TargetType: "HeartRate", CustomTargetValueLow: 250, CustomTargetValueHigh: 260, Intensity: "Active"},
+		{MessageIndex: 5, DurationType: "RepeatUntilStepsCmplt", DurationValue: 1, TargetType: "Open", TargetValue: 2, Intensity: "Active"},
+		{MessageIndex: 6, DurationType: "Time", DurationValue: 300000, TargetType: "Open", Intensity: "Cooldown"},
+	}
+	if !reflect.DeepEqual(w.Steps, want) {
+		t.Errorf("Wrong steps\nGot:  %+v\nWant: %+v", w.Steps, want)
+	}
+	if w.Sport != "cycling" {
+		t.Errorf("Got sport %v, wanted cycling", w.Sport)
+	}
+	if err := w.Renumber(); err != nil {
+		t.Errorf("The repeats are not valid: %v", err)
+	}
+
+	_, err = (&LibraryWorkout{Name: "No steps"}).Workout()
+	if err == nil {
+		t.Errorf("Expected an error for a workout without steps")
+	}
+}
+
+func TestDescriptionText(t *testing.T) {
+	w, err := goworkouts.ReadFit("../testdata/nestedrepeats.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error: %v", err)
+	}
+	w.Description = "Long\nsession"
+	desc, err := description(&w)
+	if err != nil {
+		t.Fatalf("description returned an error: %v", err)
+	}
+	if got := descriptionText(desc); got != w.Description {
+		t.Errorf("Got %q, wanted %q", got, w.Description)
+	}
+}
@@ -0,0 +1,210 @@
+package goworkouts
+
+import (
+	"errors"
+)
+
+// intensityFactors are the intensity factors used for steps without a target
+// that can be related to the athlete's thresholds
+var intensityFactors = map[string]float64{
+	"Active":   0.75,
+	"Rest":     0.4,
+	"Warmup":   0.6,
+	"Cooldown": 0.55,
+	"Recovery": 0.5,
+	"Interval": 0.9,
+	"Other":    0.65,
+}
+
+// defaultIntensityFactor is used for steps without target or known intensity
+const defaultIntensityFactor = 0.65
+
+// hrIntensity returns the heart rate target of a step relative to the
+// threshold heart rate
+func (p AthleteProfile) hrIntensity(step WorkoutStep) (float64, bool) {
+	if step.TargetValue == 0 && step.CustomTargetValueHigh > hrOffset {
+		thr := p.thresholdHR()
+		if thr <= 0 {
+			return 0, false
+		}
+		mid := float64(step.CustomTargetValueLow+step.CustomTargetValueHigh)/2 - hrOffset
+		return mid / thr, true
+	}
+	fraction, ok := p.hrFraction(step)
+	if !ok {
+		return 0, false
+	}
+	// threshold heart rate as a fraction of max HR
+	ratio := 0.9
+	if p.MaxHR > 0 && p.ThresholdHR > 0 {
+		ratio = p.ThresholdHR / p.MaxHR
+	}
+	return fraction / ratio, true
+}
+
+// IntensityFactor estimates the intensity of a step relative to the athlete's
+// threshold, from its power, heart rate or speed target. Steps without a
+// usable target get a typical value for their Intensity.
+func (p AthleteProfile) IntensityFactor(step WorkoutStep) float64 {
+	switch {
+	case isPowerTarget(step.TargetType):
+		if f, ok := p.powerFraction(step); ok {
+			return f
+		}
+	case isHRTarget(step.TargetType):
+		if f, ok := p.hrIntensity(step); ok {
+			return f
+		}
+	case isSpeedTarget(step.TargetType):
+		mid := float64(step.CustomTargetValueLow+step.CustomTargetValueHigh) / 2
+		if step.TargetValue == 0 && mid > 0 && p.ThresholdSpeed > 0 {
+			return mid / 1000 / p.ThresholdSpeed
+		}
+	}
+	if f, ok := intensityFactors[step.Intensity]; ok {
+		return f
+	}
+	return defaultIntensityFactor
+}
+
+// speed returns the speed used for distance steps of a workout
+func (p AthleteProfile) speed(w *Workout) float64 {
+	if p.Speed > 0 {
+		return p.Speed
+	}
+	return defaultSpeed(w.Sport)
+}
+
+// Load estimates the training stress score of the workout: every hour at
+// threshold adds 100. Repeats are expanded, steps without a known duration do
+// not add load.
+func (w *Workout) Load(profile AthleteProfile) (float64, error) {
+	steps, err := w.Expand()
+	if err != nil {
+		return 0, err
+	}
+	speed := profile.speed(w)
+	load := 0.0
+	for _, step := range steps {
+		d, err := StepDuration(step, speed)
+		if err != nil {
+			return 0, err
+		}
+		f := profile.IntensityFactor(step)
+		load += d.Hours() * f * f * 100
+	}
+	return load, nil
+}
+
+// LoadState is the fitness (CTL) and fatigue (ATL) of an athlete
+type LoadState struct {
+	CTL float64 `json:"ctl" yaml:"ctl"`
+	ATL float64 `json:"atl" yaml:"atl"`
+}
+
+// ProjectionOptions set the starting point and limits of ProjectLoad
+type ProjectionOptions struct {
+	Start LoadState // fitness and fatigue on the day before the plan starts
+	// CTLDays and ATLDays are the time constants of fitness and fatigue.
+	// They default to 42 and 7 days.
+	CTLDays float64
+	ATLDays float64
+	// MaxRampRate is the largest allowed CTL increase over 7 days. Defaults
+	// to 8.
+	MaxRampRate float64
+	// MinTSB is the lowest allowed form. Defaults to -30.
+	MinTSB float64
+}
+
+// LoadDay is a day of a load projection
+type LoadDay struct {
+	Order         uint32  `json:"order" yaml:"order"`
+	Load          float64 `json:"load" yaml:"load"`
+	CTL           float64 `json:"ctl" yaml:"ctl"`
+	ATL           float64 `json:"atl" yaml:"atl"`
+	TSB           float64 `json:"tsb" yaml:"tsb"`
+	RampRate      float64 `json:"rampRate" yaml:"rampRate"`
+	RampViolation bool    `json:"rampViolation" yaml:"rampViolation"`
+	LowForm       bool    `json:"lowForm" yaml:"lowForm"`
+}
+
+// LoadProjection is the daily fitness, fatigue and form over a plan
+type LoadProjection struct {
+	Days []LoadDay `json:"days" yaml:"days"`
+	// RampViolations and LowFormDays list the days (Order) on which the
+	// limits are crossed
+	RampViolations []uint32 `json:"rampViolations" yaml:"rampViolations"`
+	LowFormDays    []uint32 `json:"lowFormDays" yaml:"lowFormDays"`
+}
+
+// ProjectLoad walks the plan day by day and computes the exponentially
+// weighted fitness (CTL), fatigue (ATL) and form (TSB) from the estimated load
+// of the workouts. The form of a day is the fitness minus the fatigue of the
+// day before, so it shows how fresh the athlete starts the day. The ramp rate
+// is the CTL increase over the last 7 days.
+func (p *TrainingPlan) ProjectLoad(profile AthleteProfile, opts ProjectionOptions) (LoadProjection, error) {
+	if opts.CTLDays == 0 {
+		opts.CTLDays = 42
+	}
+	if opts.ATLDays == 0 {
+		opts.ATLDays = 7
+	}
+	if opts.MaxRampRate == 0 {
+		opts.MaxRampRate = 8
+	}
+	if opts.MinTSB == 0 {
+		opts.MinTSB = -30
+	}
+	if opts.CTLDays < 1 || opts.ATLDays < 1 {
+		return LoadProjection{}, errors.New("Time constants must be at least one day")
+	}
+
+	days := p.Duration
+	loads := map[uint32]float64{}
+	for _, day := range p.TrainingDays {
+		if day.Order > days {
+			days = day.Order
+		}
+		for i := range day.Workouts {
+			load, err := day.Workouts[i].Load(profile)
+			if err != nil {
+				return LoadProjection{}, err
+			}
+			loads[day.Order] += load
+		}
+	}
+
+	projection := LoadProjection{}
+	ctl := []float64{opts.Start.CTL}
+	state := opts.Start
+	for order := uint32(1); order <= days; order++ {
+		load := loads[order]
+		tsb := state.CTL - state.ATL
+		state.CTL += (load - state.CTL) / opts.CTLDays
+		state.ATL += (load - state.ATL) / opts.ATLDays
+		ctl = append(ctl, state.CTL)
+
+		week := 0
+		if len(ctl) > 7 {
+			week = len(ctl) - 8
+		}
+		day := LoadDay{
+			Order:    order,
+			Load:     load,
+			CTL:      state.CTL,
+			ATL:      state.ATL,
+			TSB:      tsb,
+			RampRate: state.CTL - ctl[week],
+		}
+		if day.RampRate > opts.MaxRampRate {
+			day.RampViolation = true
+			projection.RampViolations = append(projection.RampViolations, order)
+		}
+		if day.TSB < opts.MinTSB {
+			day.LowForm = true
+			projection.LowFormDays = append(projection.LowFormDays, order)
+		}
+		projection.Days = append(projection.Days, day)
+	}
+	return projection, nil
+}
@@ -0,0 +1,72 @@
+package goworkouts
+
+import (
+	"math"
+	"testing"
+)
+
+const thresholdHour = `{"workoutName": "1h FTP", "sport": "cycling", "steps": [
+	{"stepId": 0, "durationType": "Time", "durationValue": 1800000, "targetType": "Power", "targetValueLow": 95, "targetValueHigh": 105, "intensity": "Active"},
+	{"stepId": 1, "durationType": "Time", "durationValue": 900000, "targetType": "Power", "targetValueLow": 1240, "targetValueHigh": 1260, "intensity": "Active"},
+	{"stepId": 2, "durationType": "RepeatUntilStepsCmplt", "durationValue": 1, "targetValue": 2}]}`
+
+func TestLoad(t *testing.T) {
+	w, err := FromJSON(thresholdHour)
+	if err != nil {
+		t.Fatalf("FromJSON returned an error: %v", err)
+	}
+	load, err := w.Load(AthleteProfile{FTP: 250})
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	if math.Abs(load-100) > 1e-9 {
+		t.Errorf("Wanted a load of 100, got %v", load)
+	}
+
+	hr := AthleteProfile{MaxHR: 200, ThresholdHR: 170}
+	step := WorkoutStep{TargetType: "HeartRate", CustomTargetValueLow: 80, CustomTargetValueHigh: 90}
+	if f := hr.IntensityFactor(step); math.Abs(f-1) > 1e-9 {
+		t.Errorf("Wanted intensity 1, got %v", f)
+	}
+	step = WorkoutStep{TargetType: "Open", Intensity: "Rest"}
+	if f := hr.IntensityFactor(step); f != 0.4 {
+		t.Errorf("Wanted intensity 0.4, got %v", f)
+	}
+}
+
+func TestProjectLoad(t *testing.T) {
+	w, err := FromJSON(thresholdHour)
+	if err != nil {
+		t.Fatalf("FromJSON returned an error: %v", err)
+	}
+	var days []TrainingDay
+	for order := uint32(1); order <= 14; order++ {
+		days = append(days, TrainingDay{Order: order, Workouts: []Workout{w, w}})
+	}
+	plan := TrainingPlan{Name: "Every day", TrainingDays: days, Duration: 21}
+
+	projection, err := plan.ProjectLoad(AthleteProfile{FTP: 250}, ProjectionOptions{Start: LoadState{CTL: 50, ATL: 50}})
+	if err != nil {
+		t.Fatalf("ProjectLoad returned an error: %v", err)
+	}
+	if len(projection.Days) != 21 {
+		t.Fatalf("Wanted 21 days, got %v", len(projection.Days))
+	}
+	day := projection.Days[0]
+	if math.Abs(day.CTL-(50+150./42)) > 1e-9 || math.Abs(day.ATL-(50+150./7)) > 1e-9 || day.TSB != 0 {
+		t.Errorf("Wrong first day %+v", day)
+	}
+	day = projection.Days[1]
+	if math.Abs(day.TSB-(150./42-150./7)) > 1e-9 {
+		t.Errorf("Wrong form on second day %+v", day)
+	}
+	if len(projection.RampViolations) == 0 || projection.RampViolations[0] != 3 {
+		t.Errorf("Wanted ramp violations from day 3, got %v", projection.RampViolations)
+	}
+	if len(projection.LowFormDays) == 0 || projection.LowFormDays[0] != 3 {
+		t.Errorf("Wanted low form from day 3, got %v", projection.LowFormDays)
+	}
+	if projection.Days[20].Load != 0 || projection.Days[20].LowForm {
+		t.Errorf("Wanted no load and fresh form on day 21")
+	}
+}
@@ -0,0 +1,130 @@
+package goworkouts
+
+// AthleteProfile holds the thresholds needed to turn workout targets into
+// absolute intensities
+type AthleteProfile struct {
+	FTP            float64 `json:"ftp" yaml:"ftp"`                       // functional threshold power in W
+	MaxHR          float64 `json:"maxHr" yaml:"maxHr"`                   // maximum heart rate in bpm
+	ThresholdHR    float64 `json:"thresholdHr" yaml:"thresholdHr"`       // lactate threshold heart rate in bpm
+	ThresholdSpeed float64 `json:"thresholdSpeed" yaml:"thresholdSpeed"` // threshold speed in m/s
+	// Speed in m/s used for the duration of distance steps. Defaults to the
+	// DefaultSpeeds of the sport.
+	Speed float64 `json:"speed" yaml:"speed"`
+	// PowerZones are the upper limits of the power zones as a fraction of
+	// FTP. Defaults to DefaultPowerZones.
+	PowerZones []float64 `json:"powerZones" yaml:"powerZones"`
+	// HRZones are the upper limits of the heart rate zones as a fraction of
+	// max HR. Defaults to DefaultHRZones.
+	HRZones []float64 `json:"hrZones" yaml:"hrZones"`
+}
+
+// DefaultPowerZones are the upper limits of the seven Coggan power zones as a
+// fraction of FTP. The last zone has no upper limit, 2 is used for it.
+var DefaultPowerZones = []float64{0.55, 0.75, 0.90, 1.05, 1.20, 1.50, 2.0}
+
+// DefaultHRZones are the upper limits of the five heart rate zones as a
+// fraction of max HR
+var DefaultHRZones = []float64{0.60, 0.70, 0.80, 0.90, 1.0}
+
+func (p AthleteProfile) powerZones() []float64 {
+	if len(p.PowerZones) > 0 {
+		return p.PowerZones
+	}
+	return DefaultPowerZones
+}
+
+func (p AthleteProfile) hrZones() []float64 {
+	if len(p.HRZones) > 0 {
+		return p.HRZones
+	}
+	return DefaultHRZones
+}
+
+// zoneBounds returns the lower and upper limit of the 1-based zone
+func zoneBounds(zones []float64, zone uint32) (float64, float64) {
+	if zone < 1 {
+		zone = 1
+	}
+	if int(zone) > len(zones) {
+		zone = uint32(len(zones))
+	}
+	low := 0.0
+	if zone > 1 {
+		low = zones[zone-2]
+	}
+	return low, zones[zone-1]
+}
+
+// zoneOf returns the 1-based zone that fraction falls in
+func zoneOf(zones []float64, fraction float64) int {
+	for i, limit := range zones {
+		if fraction < limit {
+			return i + 1
+		}
+	}
+	return len(zones)
+}
+
+// thresholdHR returns the threshold heart rate, estimated from the max HR
+// when it is not set
+func (p AthleteProfile) thresholdHR() float64 {
+	if p.ThresholdHR > 0 {
+		return p.ThresholdHR
+	}
+	return 0.9 * p.MaxHR
+}
+
+// powerRange returns the power target range of a step as fractions of FTP
+func (p AthleteProfile) powerRange(step WorkoutStep) (float64, float64, bool) {
+	if step.TargetValue > 0 {
+		low, high := zoneBounds(p.powerZones(), step.TargetValue)
+		return low, high, true
+	}
+	low := float64(step.CustomTargetValueLow)
+	high := float64(step.CustomTargetValueHigh)
+	if high == 0 {
+		return 0, 0, false
+	}
+	if high > powerOffset {
+		if p.FTP <= 0 {
+			return 0, 0, false
+		}
+		return (low - powerOffset) / p.FTP, (high - powerOffset) / p.FTP, true
+	}
+	return low / 100, high / 100, true
+}
+
+// hrRange returns the heart rate target range of a step as fractions of max
+// HR
+func (p AthleteProfile) hrRange(step WorkoutStep) (float64, float64, bool) {
+	if step.TargetValue > 0 {
+		low, high := zoneBounds(p.hrZones(), step.TargetValue)
+		return low, high, true
+	}
+	low := float64(step.CustomTargetValueLow)
+	high := float64(step.CustomTargetValueHigh)
+	if high == 0 {
+		return 0, 0, false
+	}
+	if high > hrOffset {
+		if p.MaxHR <= 0 {
+			return 0, 0, false
+		}
+		return (low - hrOffset) / p.MaxHR, (high - hrOffset) / p.MaxHR, true
+	}
+	return low / 100, high / 100, true
+}
+
+// powerFraction returns the middle of the power target of a step as a
+// fraction of FTP
+func (p AthleteProfile) powerFraction(step WorkoutStep) (float64, bool) {
+	low, high, ok := p.powerRange(step)
+	return (low + high) / 2, ok
+}
+
+// hrFraction returns the middle of the heart rate target of a step as a
+// fraction of max HR
+func (p AthleteProfile) hrFraction(step WorkoutStep) (float64, bool) {
+	low, high, ok := p.hrRange(step)
+	return (low + high) / 2, ok
+}
@@ -0,0 +1,312 @@
+package goworkouts
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/tormoder/fit/dyncrc16"
+)
+
+// The fit package decodes FIT files into typed messages and encodes them
+// back. Where that is not enough, like for a stable order of the fields, the
+// files are handled as a list of raw records.
+
+// Record header bits
+const (
+	rawCompressedHeader = 0x80
+	rawDefinitionHeader = 0x40
+	rawDeveloperHeader  = 0x20
+	rawLocalMask        = 0x0F
+)
+
+// rawMaxFields is the most fields, and rawMaxSize the most bytes per field, a
+// definition record can describe
+const (
+	rawMaxFields = 255
+	rawMaxSize   = 255
+)
+
+// checkFieldSize returns an error if a value does not fit in a field
+func checkFieldSize(num byte, value []byte) error {
+	if len(value) > rawMaxSize {
+		return fmt.Errorf("%w: field %d has %d bytes, at most %d fit", ErrInvalidFIT, num, len(value), rawMaxSize)
+	}
+	return nil
+}
+
+// rawField is a field of a definition record
+type rawField struct {
+	Num      byte
+	Size     byte
+	BaseType byte // developer data index for developer fields
+}
+
+// rawDef is the definition of a local message type
+type rawDef struct {
+	BigEndian bool
+	Global    uint16
+	Fields    []rawField
+	DevFields []rawField
+}
+
+// size returns the size of the data records of the definition
+func (d *rawDef) size() int {
+	size := 0
+	for _, f := range d.Fields {
+		size += int(f.Size)
+	}
+	for _, f := range d.DevFields {
+		size += int(f.Size)
+	}
+	return size
+}
+
+// rawRecord is a data record of a FIT file with its definition
+type rawRecord struct {
+	Header byte
+	Def    *rawDef
+	Data   []byte
+}
+
+// local returns the local message type of the record
+func (r *rawRecord) local() byte {
+	if r.Header&rawCompressedHeader != 0 {
+		return (r.Header >> 5) & 0x03
+	}
+	return r.Header & rawLocalMask
+}
+
+// offset returns the position of field num in the data of the record
+func (r *rawRecord) offset(num byte) (int, int, bool) {
+	pos := 0
+	for i, f := range r.Def.Fields {
+		if f.Num == num {
+			return pos, i, true
+		}
+		pos += int(f.Size)
+	}
+	return pos, len(r.Def.Fields), false
+}
+
+// field returns the value of field num
+func (r *rawRecord) field(num byte) ([]byte, bool) {
+	pos, i, ok := r.offset(num)
+	if !ok {
+		return nil, false
+	}
+	return r.Data[pos : pos+int(r.Def.Fields[i].Size)], true
+}
+
+// setField sets the value of field num, adding the field to a new definition
+// of the record if needed. A new field goes before the first field with a
+// higher number.
+func (r *rawRecord) setField(num byte, baseType byte, value []byte) error {
+	err := checkFieldSize(num, value)
+	if err != nil {
+		return err
+	}
+	def := *r.Def
+	def.Fields = append([]rawField{}, r.Def.Fields...)
+	field := rawField{Num: num, Size: byte(len(value)), BaseType: baseType}
+	pos, i, ok := r.offset(num)
+	end := pos
+	if ok {
+		end += int(def.Fields[i].Size)
+		def.Fields[i] = field
+	} else {
+		if len(def.Fields) >= rawMaxFields {
+			return fmt.Errorf("%w: more than %d fields in message %d", ErrInvalidFIT, rawMaxFields, def.Global)
+		}
+		pos, i = 0, 0
+		for i < len(def.Fields) && def.Fields[i].Num < num {
+			pos += int(def.Fields[i].Size)
+			i++
+		}
+		end = pos
+		def.Fields = append(def.Fields[:i], append([]rawField{field}, def.Fields[i:]...)...)
+	}
+	data := append([]byte{}, r.Data[:pos]...)
+	data = append(data, value...)
+	r.Data = append(data, r.Data[end:]...)
+	r.Def = &def
+	return nil
+}
+
+// rawFIT is a FIT file as its header and list of data records. The
+// definition records are written where the definition of a local message
+// type changes.
+type rawFIT struct {
+	Header  []byte
+	Records []rawRecord
+}
+
+// parseRawFIT splits the first FIT file in data in records. The CRC is not
+// checked.
+func parseRawFIT(data []byte) (*rawFIT, error) {
+	if len(data) < 12 || (data[0] != 12 && data[0] != 14) || len(data) < int(data[0]) {
+		return nil, ErrInvalidFIT
+	}
+	hsize := int(data[0])
+	size := int(binary.LittleEndian.Uint32(data[4:8]))
+	if string(data[8:12]) != ".FIT" || size > len(data)-hsize {
+		return nil, ErrInvalidFIT
+	}
+	f := &rawFIT{Header: append([]byte{}, data[:hsize]...)}
+	body := data[hsize : hsize+size]
+
+	defs := map[byte]*rawDef{}
+	for pos := 0; pos < len(body); {
+		header := body[pos]
+		pos++
+		if header&rawCompressedHeader == 0 && header&rawDefinitionHeader != 0 {
+			if pos+5 > len(body) {
+				return nil, fmt.Errorf("%w: truncated definition record", ErrInvalidFIT)
+			}
+			def := &rawDef{BigEndian: body[pos+1] == 1}
+			if def.BigEndian {
+				def.Global = binary.BigEndian.Uint16(body[pos+2:])
+			} else {
+				def.Global = binary.LittleEndian.Uint16(body[pos+2:])
+			}
+			n := int(body[pos+4])
+			fields, next, err := parseRawFields(body, pos+5, n)
+			if err != nil {
+				return nil, err
+			}
+			def.Fields, pos = fields, next
+			if header&rawDeveloperHeader != 0 {
+				if pos >= len(body) {
+					return nil, fmt.Errorf("%w: truncated definition record", ErrInvalidFIT)
+				}
+				n = int(body[pos])
+				fields, next, err = parseRawFields(body, pos+1, n)
+				if err != nil {
+					return nil, err
+				}
+				def.DevFields, pos = fields, next
+			}
+			defs[header&rawLocalMask] = def
+			continue
+		}
+
+		r := rawRecord{Header: header}
+		def, ok := defs[r.local()]
+		if !ok {
+			return nil, fmt.Errorf("%w: data record of undefined local message %d", ErrInvalidFIT, r.local())
+		}
+		if pos+def.size() > len(body) {
+			return nil, fmt.Errorf("%w: truncated data record", ErrInvalidFIT)
+		}
+		r.Def = def
+		r.Data = body[pos : pos+def.size()]
+		pos += def.size()
+		f.Records = append(f.Records, r)
+	}
+	return f, nil
+}
+
+// parseRawFields reads n field definitions from body at pos
+func parseRawFields(body []byte, pos int, n int) ([]rawField, int, error) {
+	if pos+3*n > len(body) {
+		return nil, pos, fmt.Errorf("%w: truncated definition record", ErrInvalidFIT)
+	}
+	fields := make([]rawField, n)
+	for i := range fields {
+		fields[i] = rawField{Num: body[pos], Size: body[pos+1], BaseType: body[pos+2]}
+		pos += 3
+	}
+	return fields, pos, nil
+}
+
+// appendDefinition appends the definition record of def as local message
+// type local to body
+func appendDefinition(body []byte, local byte, def *rawDef) []byte {
+	header := rawDefinitionHeader | local
+	if len(def.DevFields) > 0 {
+		header |= rawDeveloperHeader
+	}
+	var arch byte
+	order := binary.ByteOrder(binary.LittleEndian)
+	if def.BigEndian {
+		arch = 1
+		order = binary.BigEndian
+	}
+	body = append(body, header, 0, arch, 0, 0)
+	order.PutUint16(body[len(body)-2:], def.Global)
+	body = append(body, byte(len(def.Fields)))
+	for _, field := range def.Fields {
+		body = append(body, field.Num, field.Size, field.BaseType)
+	}
+	if len(def.DevFields) > 0 {
+		body = append(body, byte(len(def.DevFields)))
+		for _, field := range def.DevFields {
+			body = append(body, field.Num, field.Size, field.BaseType)
+		}
+	}
+	return body
+}
+
+// bytes encodes the file, with new data size and CRCs
+func (f *rawFIT) bytes() []byte {
+	var body []byte
+	defined := map[byte]*rawDef{}
+	for _, r := range f.Records {
+		if !equalDefs(defined[r.local()], r.Def) {
+			body = appendDefinition(body, r.local(), r.Def)
+			defined[r.local()] = r.Def
+		}
+		body = append(body, r.Header)
+		body = append(body, r.Data...)
+	}
+
+	out := append([]byte{}, f.Header...)
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(body)))
+	if len(out) == 14 {
+		binary.LittleEndian.PutUint16(out[12:14], dyncrc16.Checksum(out[:12]))
+	}
+	out = append(out, body...)
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, dyncrc16.Checksum(out))
+	return append(out, crc...)
+}
+
+// canonicalFIT sorts the fields of every definition of a FIT file by field
+// number and reorders the data records to match, so the same messages always
+// encode to the same bytes
+func canonicalFIT(data []byte) ([]byte, error) {
+	f, err := parseRawFIT(data)
+	if err != nil {
+		return nil, err
+	}
+	sorted := map[*rawDef]*rawDef{}
+	for i, r := range f.Records {
+		def, ok := sorted[r.Def]
+		if !ok {
+			def = &rawDef{BigEndian: r.Def.BigEndian, Global: r.Def.Global, DevFields: r.Def.DevFields}
+			def.Fields = append([]rawField{}, r.Def.Fields...)
+			sort.SliceStable(def.Fields, func(a, b int) bool { return def.Fields[a].Num < def.Fields[b].Num })
+			sorted[r.Def] = def
+		}
+		f.Records[i].Data = reorderFields(r.Data, r.Def, def)
+		f.Records[i].Def = def
+	}
+	return f.bytes(), nil
+}
+
+// reorderFields moves the field values of a data record from the order of
+// definition from to the order of definition to
+func reorderFields(data []byte, from *rawDef, to *rawDef) []byte {
+	values := map[byte][]byte{}
+	pos := 0
+	for _, field := range from.Fields {
+		values[field.Num] = data[pos : pos+int(field.Size)]
+		pos += int(field.Size)
+	}
+	out := make([]byte, 0, len(data))
+	for _, field := range to.Fields {
+		out = append(out, values[field.Num]...)
+	}
+	// developer fields keep their order
+	return append(out, data[pos:]...)
+}
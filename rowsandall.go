@@ -0,0 +1,393 @@
+package goworkouts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tormoder/fit"
+)
+
+// Rowsandall keeps planned sessions in its own JSON schema, which looked like
+// the JSON of TrainingPlan when it was made. The types below follow that
+// schema, so the two can change independently. Keys the types do not know are
+// kept in Extra and written back, and ReadRowsandallPlan reports them, and
+// the keys it misses, as schema drift.
+
+// RowsandallStep is a step of a Rowsandall workout. Description is the text
+// of the step, not of the workout.
+type RowsandallStep struct {
+	StepID          int    `json:"stepId"`
+	WktStepName     string `json:"wkt_step_name"`
+	DurationType    string `json:"durationType"`
+	DurationValue   uint32 `json:"durationValue"`
+	TargetType      string `json:"targetType"`
+	TargetValue     uint32 `json:"targetValue"`
+	TargetValueLow  uint32 `json:"targetValueLow"`
+	TargetValueHigh uint32 `json:"targetValueHigh"`
+	Intensity       string `json:"intensity"`
+	Description     string `json:"description"`
+	// Extra holds the keys of the step that are not in the schema
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// RowsandallWorkout is a workout of a Rowsandall plan
+type RowsandallWorkout struct {
+	Filename    string           `json:"filename"`
+	WorkoutName string           `json:"workoutName"`
+	Steps       []RowsandallStep `json:"steps"`
+	Sport       string           `json:"sport"`
+	Description string           `json:"description"`
+	// Extra holds the keys of the workout that are not in the schema
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// RowsandallDay is a day of a Rowsandall plan
+type RowsandallDay struct {
+	Order    uint32              `json:"order"`
+	Workouts []RowsandallWorkout `json:"workouts"`
+	// Extra holds the keys of the day that are not in the schema
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// RowsandallPlan is a Rowsandall plan of planned sessions, like
+// RowsandallTPdummy.json
+type RowsandallPlan struct {
+	TrainingDays []RowsandallDay `json:"trainingDays"`
+	Duration     uint32          `json:"duration"` // in number of calendar days
+	// Extra holds the keys of the plan that are not in the schema
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// DriftKind says how a document differs from the schema
+type DriftKind string
+
+const (
+	// DriftUnknown is a key that is not in the schema. Its value is kept in
+	// Extra.
+	DriftUnknown DriftKind = "unknown"
+	// DriftMissing is a key of the schema that is not in the document. Its
+	// value is zero.
+	DriftMissing DriftKind = "missing"
+)
+
+// SchemaDrift is a difference between a document and the schema
+type SchemaDrift struct {
+	Path string    `json:"path" yaml:"path"` // like trainingDays[0].workouts[1].steps[2].hr
+	Kind DriftKind `json:"kind" yaml:"kind"`
+}
+
+func (d SchemaDrift) String() string {
+	return fmt.Sprintf("%v key %v", d.Kind, d.Path)
+}
+
+// jsonKeys returns the JSON keys of the fields of a struct type, in the order
+// of the fields, with their types
+func jsonKeys(t reflect.Type) ([]string, map[string]reflect.Type) {
+	var keys []string
+	types := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if key == "-" || field.PkgPath != "" {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		keys = append(keys, key)
+		types[key] = field.Type
+	}
+	return keys, types
+}
+
+// decodeObject decodes a JSON object into v, a pointer to a struct, and
+// returns the keys that v does not have
+func decodeObject(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	var object map[string]json.RawMessage
+	err := json.Unmarshal(data, &object)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return nil, err
+	}
+	_, known := jsonKeys(reflect.TypeOf(v).Elem())
+	var extra map[string]json.RawMessage
+	for key, raw := range object {
+		if _, ok := known[key]; ok {
+			continue
+		}
+		if extra == nil {
+			extra = map[string]json.RawMessage{}
+		}
+		extra[key] = raw
+	}
+	return extra, nil
+}
+
+// marshalRaw marshals v without escaping HTML characters
+func marshalRaw(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), err
+}
+
+// encodeObject marshals v, a struct, followed by the extra keys in sorted
+// order
+func encodeObject(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := marshalRaw(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	buf := bytes.NewBuffer(data[:len(data)-1])
+	for _, key := range keys {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := marshalRaw(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(extra[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON keeps the unknown keys in Extra
+func (s *RowsandallStep) UnmarshalJSON(data []byte) error {
+	type plain RowsandallStep
+	extra, err := decodeObject(data, (*plain)(s))
+	s.Extra = extra
+	return err
+}
+
+// MarshalJSON writes the unknown keys after the others
+func (s RowsandallStep) MarshalJSON() ([]byte, error) {
+	type plain RowsandallStep
+	return encodeObject(plain(s), s.Extra)
+}
+
+// UnmarshalJSON keeps the unknown keys in Extra
+func (w *RowsandallWorkout) UnmarshalJSON(data []byte) error {
+	type plain RowsandallWorkout
+	extra, err := decodeObject(data, (*plain)(w))
+	w.Extra = extra
+	return err
+}
+
+// MarshalJSON writes the unknown keys after the others
+func (w RowsandallWorkout) MarshalJSON() ([]byte, error) {
+	type plain RowsandallWorkout
+	return encodeObject(plain(w), w.Extra)
+}
+
+// UnmarshalJSON keeps the unknown keys in Extra
+func (d *RowsandallDay) UnmarshalJSON(data []byte) error {
+	type plain RowsandallDay
+	extra, err := decodeObject(data, (*plain)(d))
+	d.Extra = extra
+	return err
+}
+
+// MarshalJSON writes the unknown keys after the others
+func (d RowsandallDay) MarshalJSON() ([]byte, error) {
+	type plain RowsandallDay
+	return encodeObject(plain(d), d.Extra)
+}
+
+// UnmarshalJSON keeps the unknown keys in Extra
+func (p *RowsandallPlan) UnmarshalJSON(data []byte) error {
+	type plain RowsandallPlan
+	extra, err := decodeObject(data, (*plain)(p))
+	p.Extra = extra
+	return err
+}
+
+// MarshalJSON writes the unknown keys after the others
+func (p RowsandallPlan) MarshalJSON() ([]byte, error) {
+	type plain RowsandallPlan
+	return encodeObject(plain(p), p.Extra)
+}
+
+// schemaDrift adds the differences between the JSON value raw at path and
+// the schema of type t to drift
+func schemaDrift(raw json.RawMessage, t reflect.Type, path string, drift *[]SchemaDrift) {
+	switch t.Kind() {
+	case reflect.Slice:
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return
+		}
+		for i, item := range items {
+			schemaDrift(item, t.Elem(), fmt.Sprintf("%v[%d]", path, i), drift)
+		}
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(raw, &object) != nil || object == nil {
+			return
+		}
+		prefix := path
+		if prefix != "" {
+			prefix += "."
+		}
+		keys, types := jsonKeys(t)
+		for _, key := range keys {
+			value, ok := object[key]
+			if !ok {
+				*drift = append(*drift, SchemaDrift{Path: prefix + key, Kind: DriftMissing})
+				continue
+			}
+			schemaDrift(value, types[key], prefix+key, drift)
+		}
+		var unknown []string
+		for key := range object {
+			if _, ok := types[key]; !ok {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			*drift = append(*drift, SchemaDrift{Path: prefix + key, Kind: DriftUnknown})
+		}
+	}
+}
+
+// ReadRowsandallPlan reads a Rowsandall plan and reports where it differs
+// from the schema. Unknown keys are kept in the Extra fields, missing keys
+// are zero.
+func ReadRowsandallPlan(data []byte) (RowsandallPlan, []SchemaDrift, error) {
+	var p RowsandallPlan
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return RowsandallPlan{}, nil, err
+	}
+	var drift []SchemaDrift
+	schemaDrift(data, reflect.TypeOf(p), "", &drift)
+	return p, drift, nil
+}
+
+// ReadRowsandallWorkout reads a single Rowsandall workout and reports where
+// it differs from the schema, see ReadRowsandallPlan
+func ReadRowsandallWorkout(data []byte) (RowsandallWorkout, []SchemaDrift, error) {
+	var w RowsandallWorkout
+	err := json.Unmarshal(data, &w)
+	if err != nil {
+		return RowsandallWorkout{}, nil, err
+	}
+	var drift []SchemaDrift
+	schemaDrift(data, reflect.TypeOf(w), "", &drift)
+	return w, drift, nil
+}
+
+// Marshal writes the plan the way Rowsandall does, indented by three spaces
+func (p *RowsandallPlan) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "   ")
+	err := enc.Encode(p)
+	return buf.Bytes(), err
+}
+
+// WorkoutStep returns the step as a goworkouts step, with the description of
+// the step in Notes
+func (s *RowsandallStep) WorkoutStep() WorkoutStep {
+	return WorkoutStep{
+		MessageIndex:          fit.MessageIndex(s.StepID),
+		WktStepName:           s.WktStepName,
+		DurationType:          s.DurationType,
+		DurationValue:         s.DurationValue,
+		TargetType:            s.TargetType,
+		TargetValue:           s.TargetValue,
+		CustomTargetValueLow:  s.TargetValueLow,
+		CustomTargetValueHigh: s.TargetValueHigh,
+		Intensity:             s.Intensity,
+		Notes:                 s.Description,
+	}
+}
+
+// Workout returns the workout as a goworkouts workout
+func (w *RowsandallWorkout) Workout() Workout {
+	out := Workout{
+		Filename:    w.Filename,
+		Name:        w.WorkoutName,
+		Sport:       w.Sport,
+		Description: w.Description,
+	}
+	for i := range w.Steps {
+		out.Steps = append(out.Steps, w.Steps[i].WorkoutStep())
+	}
+	return out
+}
+
+// TrainingPlan returns the plan as a goworkouts plan
+func (p *RowsandallPlan) TrainingPlan() TrainingPlan {
+	out := TrainingPlan{Duration: p.Duration}
+	for _, day := range p.TrainingDays {
+		d := TrainingDay{Order: day.Order}
+		for j := range day.Workouts {
+			d.Workouts = append(d.Workouts, day.Workouts[j].Workout())
+		}
+		out.TrainingDays = append(out.TrainingDays, d)
+	}
+	return out
+}
+
+// NewRowsandallStep returns a goworkouts step as a Rowsandall step. FIT
+// extensions are left out.
+func NewRowsandallStep(step WorkoutStep) RowsandallStep {
+	return RowsandallStep{
+		StepID:          int(step.MessageIndex),
+		WktStepName:     step.WktStepName,
+		DurationType:    step.DurationType,
+		DurationValue:   step.DurationValue,
+		TargetType:      step.TargetType,
+		TargetValue:     step.TargetValue,
+		TargetValueLow:  step.CustomTargetValueLow,
+		TargetValueHigh: step.CustomTargetValueHigh,
+		Intensity:       step.Intensity,
+		Description:     step.Notes,
+	}
+}
+
+// NewRowsandallWorkout returns a goworkouts workout as a Rowsandall workout.
+// The optional FIT fields, like SubSport, are left out.
+func NewRowsandallWorkout(w *Workout) RowsandallWorkout {
+	out := RowsandallWorkout{
+		Filename:    w.Filename,
+		WorkoutName: w.Name,
+		Steps:       []RowsandallStep{},
+		Sport:       w.Sport,
+		Description: w.Description,
+	}
+	for _, step := range w.Steps {
+		out.Steps = append(out.Steps, NewRowsandallStep(step))
+	}
+	return out
+}
+
+// NewRowsandallPlan returns a goworkouts plan as a Rowsandall plan. The ID,
+// name, description, weeks and phases of the plan are left out.
+func NewRowsandallPlan(p *TrainingPlan) RowsandallPlan {
+	out := RowsandallPlan{TrainingDays: []RowsandallDay{}, Duration: p.Duration}
+	for _, day := range p.TrainingDays {
+		d := RowsandallDay{Order: day.Order, Workouts: []RowsandallWorkout{}}
+		for j := range day.Workouts {
+			d.Workouts = append(d.Workouts, NewRowsandallWorkout(&day.Workouts[j]))
+		}
+		out.TrainingDays = append(out.TrainingDays, d)
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package goworkouts
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRowsandallPlan(t *testing.T) {
+	data, err := ioutil.ReadFile("RowsandallTPdummy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, drift, err := ReadRowsandallPlan(data)
+	if err != nil {
+		t.Fatalf("ReadRowsandallPlan returned an error: %v", err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("Unexpected drift %v", drift)
+	}
+	out, err := p.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Errorf("The plan is not written the way it was read\nGot:\n%s", out)
+	}
+
+	plan := p.TrainingPlan()
+	back := NewRowsandallPlan(&plan)
+	out, err = back.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Errorf("The plan changed on the way through TrainingPlan\nGot:\n%s", out)
+	}
+}
+
+func TestRowsandallDescriptions(t *testing.T) {
+	w := Workout{
+		Name:        "2k",
+		Sport:       "rowing",
+		Description: "Test day",
+		Steps: []WorkoutStep{
+			{DurationType: "Distance", DurationValue: 200000, TargetType: "Open", Intensity: "Active", Notes: "All out"},
+		},
+	}
+	rw := NewRowsandallWorkout(&w)
+	if rw.Description != "Test day" || rw.Steps[0].Description != "All out" {
+		t.Errorf("Wrong descriptions %+v", rw)
+	}
+	if got := rw.Workout(); !reflect.DeepEqual(got, w) {
+		t.Errorf("Round trip changed the workout\nGot:  %+v\nWant: %+v", got, w)
+	}
+}
+
+func TestRowsandallDrift(t *testing.T) {
+	data, err := ioutil.ReadFile("RowsandallTPdummy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	s = strings.Replace(s, `"stepId": 1,`, `"stepId": 1, "hr": 150,`, 1)
+	s = strings.Replace(s, `"sport": "",`, ``, 1)
+	s = strings.Replace(s, `"duration": 4`, `"duration": 4, "name": "Spring"`, 1)
+
+	p, drift, err := ReadRowsandallPlan([]byte(s))
+	if err != nil {
+		t.Fatalf("ReadRowsandallPlan returned an error: %v", err)
+	}
+	want := []SchemaDrift{
+		{"trainingDays[0].workouts[0].steps[1].hr", DriftUnknown},
+		{"trainingDays[0].workouts[0].sport", DriftMissing},
+		{"name", DriftUnknown},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("Wrong drift\nGot:  %v\nWant: %v", drift, want)
+	}
+
+	out, err := p.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+	for _, kept := range []string{`"hr": 150`, `"name": "Spring"`} {
+		if !bytes.Contains(out, []byte(kept)) {
+			t.Errorf("Unknown key %v is not kept", kept)
+		}
+	}
+
+	// the workouts Rowsandall sends to FromJSON name the workout "name"
+	_, drift, err = ReadRowsandallWorkout([]byte(`{"name": "", "sport": "rowing", "filename": "", "steps": [{"wkt_step_name": "0", "stepId": 0, "durationType": "Time", "durationValue": 120000, "intensity": "Rest"}]}`))
+	if err != nil {
+		t.Fatalf("ReadRowsandallWorkout returned an error: %v", err)
+	}
+	found := map[SchemaDrift]bool{}
+	for _, d := range drift {
+		found[d] = true
+	}
+	for _, d := range []SchemaDrift{{"workoutName", DriftMissing}, {"name", DriftUnknown}, {"steps[0].targetType", DriftMissing}} {
+		if !found[d] {
+			t.Errorf("Drift %v is not reported, got %v", d, drift)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package goworkouts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// FIT offsets for custom target and duration values. Power values above
+// powerOffset are in Watts + 1000, below it in % of FTP. Heart rate values
+// above hrOffset are in bpm + 100, below it in % of max HR.
+const (
+	powerOffset = 1000
+	hrOffset    = 100
+)
+
+// Maximum zone numbers for zone targets
+const (
+	maxHRZone    = 5
+	maxPowerZone = 7
+	maxSpeedZone = 10
+)
+
+// isPowerTarget returns true for all power target types
+func isPowerTarget(targetType string) bool {
+	switch targetType {
+	case "Power", "Power3s", "Power10s", "Power30s", "PowerLap":
+		return true
+	}
+	return false
+}
+
+// isHRTarget returns true for all heart rate target types
+func isHRTarget(targetType string) bool {
+	return targetType == "HeartRate" || targetType == "HeartRateLap"
+}
+
+// isSpeedTarget returns true for all speed target types
+func isSpeedTarget(targetType string) bool {
+	return targetType == "Speed" || targetType == "SpeedLap"
+}
+
+// scaleRound multiplies value by factor and rounds to a multiple of unit
+func scaleRound(value uint32, factor float64, unit uint32) uint32 {
+	scaled := math.Round(float64(value)*factor/float64(unit)) * float64(unit)
+	if scaled < float64(unit) && value > 0 {
+		scaled = float64(unit)
+	}
+	if scaled > float64(MaxUint-1) {
+		scaled = float64(MaxUint - 1)
+	}
+	return uint32(scaled)
+}
+
+// scaleOffset scales a value that is stored with an offset, so only the part
+// above the offset is scaled
+func scaleOffset(value uint32, factor float64, offset uint32) uint32 {
+	if value <= offset {
+		return value
+	}
+	return offset + scaleRound(value-offset, factor, 1)
+}
+
+// scalePercent scales a percentage without crossing into the absolute range
+// that starts at max
+func scalePercent(value uint32, factor float64, max uint32) uint32 {
+	scaled := scaleRound(value, factor, 1)
+	if scaled > max {
+		scaled = max
+	}
+	return scaled
+}
+
+// ScaleDuration multiplies the duration of all time, distance and calorie
+// steps by factor. Times are rounded to whole seconds, distances to whole
+// meters. Repeat steps that repeat until a time, distance or number of
+// calories are scaled too, repeat counts are left alone.
+func (w *Workout) ScaleDuration(factor float64) error {
+	if factor <= 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return fmt.Errorf("Invalid scale factor %v", factor)
+	}
+	steps := append([]WorkoutStep{}, w.Steps...)
+	for i, step := range steps {
+		switch step.DurationType {
+		case "Time", "TimeOnly", "RepetitionTime":
+			steps[i].DurationValue = scaleRound(step.DurationValue, factor, 1000)
+		case "Distance":
+			steps[i].DurationValue = scaleRound(step.DurationValue, factor, 100)
+		case "Calories", "TrainingPeaksTss":
+			steps[i].DurationValue = scaleRound(step.DurationValue, factor, 1)
+		case "RepeatUntilTime":
+			steps[i].TargetValue = scaleRound(step.TargetValue, factor, 1000)
+		case "RepeatUntilDistance":
+			steps[i].TargetValue = scaleRound(step.TargetValue, factor, 100)
+		case "RepeatUntilCalories", "RepeatUntilTrainingPeaksTss":
+			steps[i].TargetValue = scaleRound(step.TargetValue, factor, 1)
+		}
+	}
+	w.Steps = steps
+	return nil
+}
+
+// ScaleIntensity multiplies the custom power, heart rate and speed targets by
+// factor. Percentages are capped so they stay percentages (1000% of FTP, 100%
+// of max HR). Zone targets are left alone, use ShiftZones for those.
+func (w *Workout) ScaleIntensity(factor float64) error {
+	if factor <= 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return fmt.Errorf("Invalid scale factor %v", factor)
+	}
+	steps := append([]WorkoutStep{}, w.Steps...)
+	for i, step := range steps {
+		if isRepeat(step) || step.TargetValue > 0 {
+			continue
+		}
+		low := step.CustomTargetValueLow
+		high := step.CustomTargetValueHigh
+		switch {
+		case isPowerTarget(step.TargetType):
+			if high > powerOffset {
+				low = scaleOffset(low, factor, powerOffset)
+				high = scaleOffset(high, factor, powerOffset)
+			} else {
+				low = scalePercent(low, factor, powerOffset)
+				high = scalePercent(high, factor, powerOffset)
+			}
+		case isHRTarget(step.TargetType):
+			if high > hrOffset {
+				low = scaleOffset(low, factor, hrOffset)
+				high = scaleOffset(high, factor, hrOffset)
+			} else {
+				low = scalePercent(low, factor, hrOffset)
+				high = scalePercent(high, factor, hrOffset)
+			}
+		case isSpeedTarget(step.TargetType):
+			low = scaleRound(low, factor, 1)
+			high = scaleRound(high, factor, 1)
+		}
+		steps[i].CustomTargetValueLow = low
+		steps[i].CustomTargetValueHigh = high
+	}
+	w.Steps = steps
+	return nil
+}
+
+// SetRepeats sets the number of repeats of the repeat step at position
+// blockIdx
+func (w *Workout) SetRepeats(blockIdx int, n uint32) error {
+	err := w.checkPosition(blockIdx)
+	if err != nil {
+		return err
+	}
+	if w.Steps[blockIdx].DurationType != "RepeatUntilStepsCmplt" {
+		return stepError(blockIdx, "DurationType", "Step does not repeat a number of times")
+	}
+	if n < 1 {
+		return errors.New("Number of repeats must be at least 1")
+	}
+	steps := append([]WorkoutStep{}, w.Steps...)
+	steps[blockIdx].TargetValue = n
+	w.Steps = steps
+	return nil
+}
+
+// shiftZone adds delta to zone, keeping it between 1 and max
+func shiftZone(zone uint32, delta int, max int) uint32 {
+	shifted := int(zone) + delta
+	if shifted < 1 {
+		shifted = 1
+	}
+	if shifted > max {
+		shifted = max
+	}
+	return uint32(shifted)
+}
+
+// ShiftZones adds delta to all power, heart rate and speed zone targets. The
+// zones are kept within the range defined by FIT (HR 1-5, power 1-7, speed
+// 1-10).
+func (w *Workout) ShiftZones(delta int) {
+	steps := append([]WorkoutStep{}, w.Steps...)
+	for i, step := range steps {
+		if isRepeat(step) || step.TargetValue == 0 {
+			continue
+		}
+		switch {
+		case isPowerTarget(step.TargetType):
+			steps[i].TargetValue = shiftZone(step.TargetValue, delta, maxPowerZone)
+		case isHRTarget(step.TargetType):
+			steps[i].TargetValue = shiftZone(step.TargetValue, delta, maxHRZone)
+		case isSpeedTarget(step.TargetType):
+			steps[i].TargetValue = shiftZone(step.TargetValue, delta, maxSpeedZone)
+		}
+	}
+	w.Steps = steps
+}
+
+// Progression changes a workout of the plan. Week is the zero based week of
+// the training day the workout is on.
+type Progression func(w *Workout, week int) error
+
+// WeeklyVolumeIncrease returns a Progression that increases the duration of
+// all workouts by pct percent per week, compounded
+func WeeklyVolumeIncrease(pct float64) Progression {
+	return func(w *Workout, week int) error {
+		return w.ScaleDuration(math.Pow(1+pct/100, float64(week)))
+	}
+}
+
+// WeeklyIntensityIncrease returns a Progression that increases the custom
+// targets of all workouts by pct percent per week, compounded
+func WeeklyIntensityIncrease(pct float64) Progression {
+	return func(w *Workout, week int) error {
+		return w.ScaleIntensity(math.Pow(1+pct/100, float64(week)))
+	}
+}
+
+// dayWeek returns the zero based week of a training day
+func (p *TrainingPlan) dayWeek(day TrainingDay) int {
+	return int(p.WeekOf(day.Order)) - 1
+}
+
+// ApplyProgression applies rule to every workout in the plan. The plan is
+// only changed if the rule succeeds for all workouts.
+func (p *TrainingPlan) ApplyProgression(rule Progression) error {
+	days := make([]TrainingDay, len(p.TrainingDays))
+	for i, day := range p.TrainingDays {
+		days[i] = day
+		days[i].Workouts = make([]Workout, len(day.Workouts))
+		for j, w := range day.Workouts {
+			w.Steps = append([]WorkoutStep{}, w.Steps...)
+			err := rule(&w, p.dayWeek(day))
+			if err != nil {
+				return fmt.Errorf("Day %d, workout %d: %w", day.Order, j, err)
+			}
+			days[i].Workouts[j] = w
+		}
+	}
+	p.TrainingDays = days
+	return nil
+}
@@ -0,0 +1,118 @@
+package goworkouts
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestScaleDuration(t *testing.T) {
+	w, err := ReadFit("testdata/fitsdk/WorkoutRepeatSteps.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	err = w.ScaleDuration(1.1)
+	if err != nil {
+		t.Fatalf("ScaleDuration returned an error: %v", err)
+	}
+	// 60s, 500m, 500m, 3 repeats, HR < 125
+	want := []uint32{66000, 55000, 55000, 1, 225}
+	for i, step := range w.Steps {
+		if step.DurationValue != want[i] {
+			t.Errorf("Step %d: wanted duration %v, got %v", i, want[i], step.DurationValue)
+		}
+	}
+	if w.Steps[3].TargetValue != 3 {
+		t.Errorf("Repeat count should not be scaled")
+	}
+	err = w.ScaleDuration(0)
+	if err == nil {
+		t.Errorf("Should have thrown an error")
+	}
+}
+
+func TestScaleIntensity(t *testing.T) {
+	w, err := ReadFit("testdata/fitsdk/WorkoutCustomTargetValues.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	err = w.ScaleIntensity(1.05)
+	if err != nil {
+		t.Fatalf("ScaleIntensity returned an error: %v", err)
+	}
+	// HR 50-60%, power 300-310W, 260-270W, 220-230W
+	wantLow := []uint32{53, 1315, 1273, 1231}
+	wantHigh := []uint32{63, 1326, 1284, 1242}
+	for i, step := range w.Steps {
+		if step.CustomTargetValueLow != wantLow[i] || step.CustomTargetValueHigh != wantHigh[i] {
+			t.Errorf("Step %d: wanted %v-%v, got %v-%v", i, wantLow[i], wantHigh[i],
+				step.CustomTargetValueLow, step.CustomTargetValueHigh)
+		}
+	}
+
+	err = w.ScaleIntensity(2)
+	if err != nil {
+		t.Fatalf("ScaleIntensity returned an error: %v", err)
+	}
+	if w.Steps[0].CustomTargetValueHigh != 100 {
+		t.Errorf("HR percentage should be capped at 100, got %v", w.Steps[0].CustomTargetValueHigh)
+	}
+}
+
+func TestSetRepeatsShiftZones(t *testing.T) {
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	err = w.SetRepeats(3, 8)
+	if err != nil {
+		t.Fatalf("SetRepeats returned an error: %v", err)
+	}
+	if w.Steps[3].TargetValue != 8 {
+		t.Errorf("Repeat count not set")
+	}
+	err = w.SetRepeats(1, 8)
+	if err == nil {
+		t.Errorf("Should have thrown an error")
+	}
+
+	w.ShiftZones(1)
+	if w.Steps[1].TargetValue != 6 {
+		t.Errorf("Wanted power zone 6, got %v", w.Steps[1].TargetValue)
+	}
+	w.ShiftZones(5)
+	if w.Steps[1].TargetValue != 7 {
+		t.Errorf("Wanted power zone 7, got %v", w.Steps[1].TargetValue)
+	}
+	if w.Steps[3].TargetValue != 8 {
+		t.Errorf("Repeat count should not be shifted")
+	}
+}
+
+func TestApplyProgression(t *testing.T) {
+	w, err := ReadFit("testdata/4x15min.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	days := []TrainingDay{
+		{Order: 1, Workouts: []Workout{w}},
+		{Order: 8, Workouts: []Workout{w}},
+		{Order: 15, Workouts: []Workout{w}},
+	}
+	plan := TrainingPlan{ID: uuid.New(), Name: "Test Plan", TrainingDays: days, Duration: 21}
+
+	err = plan.ApplyProgression(WeeklyVolumeIncrease(10))
+	if err != nil {
+		t.Fatalf("ApplyProgression returned an error: %v", err)
+	}
+	want := []uint32{900000, 990000, 1089000}
+	for i, day := range plan.TrainingDays {
+		got := day.Workouts[0].Steps[1].DurationValue
+		if got != want[i] {
+			t.Errorf("Week %d: wanted %v, got %v", i, want[i], got)
+		}
+	}
+	if w.Steps[1].DurationValue != 900000 {
+		t.Errorf("Original workout should not change")
+	}
+}
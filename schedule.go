@@ -0,0 +1,122 @@
+package goworkouts
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"time"
+
+	"github.com/tormoder/fit"
+)
+
+// ScheduleName is the name of the schedule file written by ExportFITSchedule
+const ScheduleName = "schedule.fit"
+
+// ScheduleOptions set the dates and file IDs of a FIT schedule
+type ScheduleOptions struct {
+	Start time.Time // date of day 1 of the plan, in the athlete's time zone
+	// SerialNumber goes in the FileId of the schedule and workout files.
+	// Defaults to a number derived from the plan ID.
+	SerialNumber uint32
+}
+
+// serialNumber returns the serial number of the files of the schedule
+func (p *TrainingPlan) serialNumber(opts ScheduleOptions) uint32 {
+	if opts.SerialNumber != 0 {
+		return opts.SerialNumber
+	}
+	serial := crc32.ChecksumIEEE(p.ID[:])
+	if serial == 0 {
+		// 0 is the invalid value of the field
+		serial = 1
+	}
+	return serial
+}
+
+// localDate returns midnight of the date of t as local date time, which FIT
+// stores as if it were UTC
+func localDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// scheduleOptions returns the FITOptions of workout file n of the schedule.
+// The files share a serial number and are created one second apart, starting
+// at the start date, so the IDs do not depend on when the files are written.
+func (p *TrainingPlan) scheduleOptions(opts ScheduleOptions) func(n int) FITOptions {
+	serial := p.serialNumber(opts)
+	created := localDate(opts.Start)
+	return func(n int) FITOptions {
+		return FITOptions{
+			Manufacturer: fit.ManufacturerGarmin,
+			SerialNumber: serial,
+			TimeCreated:  created.Add(time.Duration(n) * time.Second),
+		}
+	}
+}
+
+// ToFITSchedule creates a FIT schedule file that puts every workout of the
+// plan on its date, counting day 1 as opts.Start. The workouts are referenced
+// by the FileId of the workout files written by ExportFITSchedule.
+func (p *TrainingPlan) ToFITSchedule(opts ScheduleOptions) (*fit.File, error) {
+	if opts.Start.IsZero() {
+		return nil, errors.New("Schedule needs a start date")
+	}
+	h := fit.NewHeader(fit.V10, true)
+	f, err := fit.NewFile(fit.FileTypeSchedules, h)
+	if err != nil {
+		return f, err
+	}
+	options := p.scheduleOptions(opts)
+	// a second before the first workout file
+	id := options(-1)
+	f.FileId.Manufacturer = id.Manufacturer
+	f.FileId.SerialNumber = id.SerialNumber
+	f.FileId.TimeCreated = id.TimeCreated
+
+	schedules, err := f.Schedules()
+	if err != nil {
+		return f, err
+	}
+	start := localDate(opts.Start)
+	n := 0
+	for _, day := range p.TrainingDays {
+		for range day.Workouts {
+			id := options(n)
+			n++
+
+			msg := fit.NewScheduleMsg()
+			msg.Manufacturer = id.Manufacturer
+			msg.SerialNumber = id.SerialNumber
+			msg.TimeCreated = id.TimeCreated
+			msg.Completed = fit.BoolFalse
+			msg.Type = fit.ScheduleWorkout
+			msg.ScheduledTime = start.AddDate(0, 0, int(day.Order)-1)
+			schedules.Schedules = append(schedules.Schedules, msg)
+		}
+	}
+	return f, nil
+}
+
+// ExportFITSchedule writes the plan to dir like ExportFIT, with the FileId of
+// the workout files set for the schedule, and adds the schedule file
+// schedule.fit
+func (p *TrainingPlan) ExportFITSchedule(dir string, opts ScheduleOptions, overwrite bool) error {
+	schedule, err := p.ToFITSchedule(opts)
+	if err != nil {
+		return err
+	}
+	write, err := dirWriter(dir, overwrite)
+	if err != nil {
+		return err
+	}
+	err = p.exportFIT(write, p.scheduleOptions(opts))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = EncodeFit(&buf, schedule)
+	if err != nil {
+		return err
+	}
+	return write(ScheduleName, buf.Bytes())
+}
@@ -0,0 +1,85 @@
+package goworkouts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tormoder/fit"
+)
+
+func TestExportFITSchedule(t *testing.T) {
+	plan := bundleTestPlan(t)
+	opts := ScheduleOptions{Start: time.Date(2026, 3, 2, 18, 30, 0, 0, time.Local)}
+	dir := t.TempDir()
+	err := plan.ExportFITSchedule(dir, opts, false)
+	if err != nil {
+		t.Fatalf("ExportFITSchedule returned an error: %v", err)
+	}
+
+	data, err := os.Open(filepath.Join(dir, ScheduleName))
+	if err != nil {
+		t.Fatalf("Could not open schedule file")
+	}
+	defer data.Close()
+	f, err := fit.Decode(data)
+	if err != nil {
+		t.Fatalf("Could not decode schedule file: %v", err)
+	}
+	schedules, err := f.Schedules()
+	if err != nil {
+		t.Fatalf("Not a schedule file: %v", err)
+	}
+	if len(schedules.Schedules) != 3 {
+		t.Fatalf("Wanted 3 scheduled workouts, got %d", len(schedules.Schedules))
+	}
+
+	back, err := ImportFITBundle(dir)
+	if err != nil {
+		t.Fatalf("ImportFITBundle returned an error: %v", err)
+	}
+	dates := []string{"2026-03-03", "2026-03-03", "2026-03-10"}
+	n := 0
+	for _, day := range back.TrainingDays {
+		for _, w := range day.Workouts {
+			file, err := os.Open(filepath.Join(dir, w.Filename))
+			if err != nil {
+				t.Fatalf("Could not open %v", w.Filename)
+			}
+			wf, err := fit.Decode(file)
+			file.Close()
+			if err != nil {
+				t.Fatalf("Could not decode %v", w.Filename)
+			}
+			s := schedules.Schedules[n]
+			if s.SerialNumber != wf.FileId.SerialNumber || !s.TimeCreated.Equal(wf.FileId.TimeCreated) || s.Manufacturer != wf.FileId.Manufacturer {
+				t.Errorf("Schedule %d does not reference %v", n, w.Filename)
+			}
+			if s.ScheduledTime.Format("2006-01-02") != dates[n] {
+				t.Errorf("Schedule %d: wanted date %v, got %v", n, dates[n], s.ScheduledTime)
+			}
+			n++
+		}
+	}
+}
+
+func TestScheduleFileIDDeterministic(t *testing.T) {
+	plan := bundleTestPlan(t)
+	opts := ScheduleOptions{Start: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), SerialNumber: 1234}
+	a, err := plan.ToFITSchedule(opts)
+	if err != nil {
+		t.Fatalf("ToFITSchedule returned an error: %v", err)
+	}
+	b, err := plan.ToFITSchedule(opts)
+	if err != nil {
+		t.Fatalf("ToFITSchedule returned an error: %v", err)
+	}
+	if a.FileId != b.FileId || a.FileId.SerialNumber != 1234 {
+		t.Errorf("FileId of the schedule changed: %+v, %+v", a.FileId, b.FileId)
+	}
+	_, err = plan.ToFITSchedule(ScheduleOptions{})
+	if err == nil {
+		t.Errorf("Should have thrown an error")
+	}
+}
@@ -0,0 +1,111 @@
+package goworkouts
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion is the version of the JSON and YAML layout of a workout that
+// ToJSON and ToYAML write. FromJSON and FromYAML read all versions up to it:
+//
+//	1: no schemaVersion key, the notes of a step are in its description key
+//	2: the notes of a step are in its notes key, the description key is the
+//	   description of the workout only
+const SchemaVersion = 2
+
+// checkSchemaVersion returns an error for a version this package cannot read
+func checkSchemaVersion(version int) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("Workout has schema version %d, goworkouts reads up to version %d", version, SchemaVersion)
+	}
+	return nil
+}
+
+// migrateStepNotes returns the notes of a step of any schema version: the
+// notes key, or the description key of version 1
+func migrateStepNotes(notes string, description *string) string {
+	if notes == "" && description != nil {
+		return *description
+	}
+	return notes
+}
+
+type plainWorkout Workout
+
+// versionedWorkout is the layout of a workout with its schema version
+type versionedWorkout struct {
+	SchemaVersion int `json:"schemaVersion" yaml:"schemaVersion"`
+	plainWorkout  `yaml:",inline"`
+}
+
+// MarshalJSON writes the workout in the newest schema version
+func (w Workout) MarshalJSON() ([]byte, error) {
+	return json.Marshal(versionedWorkout{SchemaVersion, plainWorkout(w)})
+}
+
+// UnmarshalJSON reads a workout of any schema version
+func (w *Workout) UnmarshalJSON(data []byte) error {
+	var v versionedWorkout
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	err = checkSchemaVersion(v.SchemaVersion)
+	if err != nil {
+		return err
+	}
+	*w = Workout(v.plainWorkout)
+	return nil
+}
+
+// MarshalYAML writes the workout in the newest schema version
+func (w Workout) MarshalYAML() (interface{}, error) {
+	return versionedWorkout{SchemaVersion, plainWorkout(w)}, nil
+}
+
+// UnmarshalYAML reads a workout of any schema version
+func (w *Workout) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v versionedWorkout
+	err := unmarshal(&v)
+	if err != nil {
+		return err
+	}
+	err = checkSchemaVersion(v.SchemaVersion)
+	if err != nil {
+		return err
+	}
+	*w = Workout(v.plainWorkout)
+	return nil
+}
+
+type plainStep WorkoutStep
+
+// migratingStep is the layout of a step of any schema version
+type migratingStep struct {
+	plainStep   `yaml:",inline"`
+	Description *string `json:"description" yaml:"description"`
+}
+
+// UnmarshalJSON reads a step of any schema version
+func (s *WorkoutStep) UnmarshalJSON(data []byte) error {
+	var v migratingStep
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	*s = WorkoutStep(v.plainStep)
+	s.Notes = migrateStepNotes(s.Notes, v.Description)
+	return nil
+}
+
+// UnmarshalYAML reads a step of any schema version
+func (s *WorkoutStep) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v migratingStep
+	err := unmarshal(&v)
+	if err != nil {
+		return err
+	}
+	*s = WorkoutStep(v.plainStep)
+	s.Notes = migrateStepNotes(s.Notes, v.Description)
+	return nil
+}
@@ -0,0 +1,91 @@
+package goworkouts
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchemaMigration(t *testing.T) {
+	want, err := ReadFit("testdata/4x15min.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error: %v", err)
+	}
+	want.Description = "Threshold blocks"
+	readers := map[string]func(string) (Workout, error){
+		"json": FromJSON,
+		"yaml": FromYAML,
+	}
+	for ext, read := range readers {
+		data, err := ioutil.ReadFile("testdata/schema/v1." + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := read(string(data))
+		if err != nil {
+			t.Fatalf("Could not read version 1 %v: %v", ext, err)
+		}
+		if w.Description != want.Description {
+			t.Errorf("Version 1 %v: wanted workout description %q, got %q", ext, want.Description, w.Description)
+		}
+		for i, step := range w.Steps {
+			if step.Notes != want.Steps[i].Notes {
+				t.Errorf("Version 1 %v: wanted notes %q for step %d, got %q", ext, want.Steps[i].Notes, i, step.Notes)
+			}
+		}
+		if w.Steps[0].Notes == "" || w.Steps[0].Notes == w.Description {
+			t.Errorf("Version 1 %v: the notes of the steps are lost", ext)
+		}
+		if !reflect.DeepEqual(w, want) {
+			t.Errorf("Version 1 %v is not migrated\nGot:  %+v\nWant: %+v", ext, w, want)
+		}
+	}
+
+	data, err := want.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON returned an error: %v", err)
+	}
+	var object struct {
+		SchemaVersion int                          `json:"schemaVersion"`
+		Description   string                       `json:"description"`
+		Steps         []map[string]json.RawMessage `json:"steps"`
+	}
+	err = json.Unmarshal(data, &object)
+	if err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if object.SchemaVersion != SchemaVersion || object.Description != want.Description {
+		t.Errorf("Wrong workout keys in %s", data)
+	}
+	if _, ok := object.Steps[0]["description"]; ok {
+		t.Errorf("Step notes are written as description")
+	}
+	if _, ok := object.Steps[0]["notes"]; !ok {
+		t.Errorf("Step notes are not written as notes")
+	}
+
+	yamlData, err := want.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML returned an error: %v", err)
+	}
+	if !strings.HasPrefix(string(yamlData), "schemaVersion: 2\n") {
+		t.Errorf("YAML does not start with the schema version:\n%s", yamlData)
+	}
+	back, err := FromYAML(string(yamlData))
+	if err != nil || !reflect.DeepEqual(back, want) {
+		t.Errorf("YAML round trip changed the workout: %v", err)
+	}
+}
+
+func TestSchemaVersionTooNew(t *testing.T) {
+	_, err := FromJSON(`{"schemaVersion": 3, "steps": []}`)
+	if err == nil {
+		t.Errorf("Expected an error for a newer JSON schema")
+	}
+	_, err = FromYAML("schemaVersion: 3\nsteps: []\n")
+	if err == nil {
+		t.Errorf("Expected an error for a newer YAML schema")
+	}
+}
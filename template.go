@@ -0,0 +1,312 @@
+package goworkouts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// A workout template is a JSON or YAML workout in which values can be
+// replaced by placeholders like ${reps} or ${work_time}. A placeholder can
+// have a default value: ${reps:-4}. A placeholder that makes up a whole value
+// takes the type of the field it is in, a placeholder inside a longer string
+// is formatted into that string.
+
+var placeholderRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// numericFields maps the keys of workout and plan fields that hold numbers to
+// true for whole numbers and false for fractions. It is built from the json
+// tags, so new fields are included.
+var numericFields = numberKeys(reflect.TypeOf(versionedWorkout{}), reflect.TypeOf(TrainingPlan{}))
+
+// numberKeys returns the json keys of the number fields of the types and the
+// structs they contain
+func numberKeys(types ...reflect.Type) map[string]bool {
+	keys := map[string]bool{}
+	seen := map[reflect.Type]bool{}
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || seen[t] {
+			return
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if key == "-" {
+				continue
+			}
+			if key == "" {
+				key = field.Name
+			}
+			switch field.Type.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				keys[key] = true
+			case reflect.Float32, reflect.Float64:
+				keys[key] = false
+			default:
+				add(field.Type)
+			}
+		}
+	}
+	for _, t := range types {
+		add(t)
+	}
+	return keys
+}
+
+// templateParam converts a template parameter to the value that is put in the
+// workout. Numeric fields take unsigned numbers, whole numbers unless the
+// field holds fractions. time.Duration values are converted to milliseconds.
+func templateParam(name string, key string, value any) (any, error) {
+	whole, numeric := numericFields[key]
+	if !numeric {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("Parameter %q must be a string for %q, got %T", name, key, value)
+		}
+		return s, nil
+	}
+
+	var f float64
+	switch v := value.(type) {
+	case time.Duration:
+		f = float64(v.Milliseconds())
+	case int:
+		f = float64(v)
+	case int8:
+		f = float64(v)
+	case int16:
+		f = float64(v)
+	case int32:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case uint:
+		f = float64(v)
+	case uint8:
+		f = float64(v)
+	case uint16:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("Parameter %q: %w", name, err)
+		}
+		f = n
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Parameter %q must be a number for %q, got %q", name, key, v)
+		}
+		f = n
+	default:
+		return nil, fmt.Errorf("Parameter %q must be a number for %q, got %T", name, key, value)
+	}
+	if !whole {
+		if f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("Parameter %q must not be negative for %q, got %v", name, key, value)
+		}
+		return f, nil
+	}
+	if f < 0 || f > float64(MaxUint) || f != math.Trunc(f) {
+		return nil, fmt.Errorf("Parameter %q must be a whole number between 0 and %d for %q, got %v", name, MaxUint, key, value)
+	}
+	return uint64(f), nil
+}
+
+// instantiator replaces the placeholders in a decoded template
+type instantiator struct {
+	params map[string]any
+	used   map[string]bool
+}
+
+func (in *instantiator) lookup(name string, key string, def string, hasDefault bool) (any, error) {
+	value, ok := in.params[name]
+	if !ok {
+		if !hasDefault {
+			return nil, fmt.Errorf("Missing required parameter %q", name)
+		}
+		value = def
+	}
+	in.used[name] = true
+	return templateParam(name, key, value)
+}
+
+// replace replaces the placeholders in the string s, which is the value of
+// key
+func (in *instantiator) replace(key string, s string) (any, error) {
+	m := placeholderRe.FindStringSubmatchIndex(s)
+	if m == nil {
+		return s, nil
+	}
+	// the whole value is a placeholder
+	if m[0] == 0 && m[1] == len(s) {
+		return in.lookup(s[m[2]:m[3]], key, placeholderDefault(s, m), m[4] >= 0)
+	}
+	if _, ok := numericFields[key]; ok {
+		return nil, fmt.Errorf("Value %q of %q must be a single placeholder", s, key)
+	}
+
+	var err error
+	out := placeholderRe.ReplaceAllStringFunc(s, func(p string) string {
+		pm := placeholderRe.FindStringSubmatchIndex(p)
+		name := p[pm[2]:pm[3]]
+		value, ok := in.params[name]
+		if !ok {
+			if pm[4] < 0 {
+				if err == nil {
+					err = fmt.Errorf("Missing required parameter %q", name)
+				}
+				return p
+			}
+			value = placeholderDefault(p, pm)
+		}
+		in.used[name] = true
+		if d, ok := value.(time.Duration); ok {
+			return d.String()
+		}
+		return fmt.Sprint(value)
+	})
+	return out, err
+}
+
+func placeholderDefault(s string, m []int) string {
+	if m[4] < 0 {
+		return ""
+	}
+	return s[m[4]:m[5]]
+}
+
+// walk replaces the placeholders in a decoded JSON or YAML value
+func (in *instantiator) walk(key string, v any) (any, error) {
+	var err error
+	switch t := v.(type) {
+	case string:
+		return in.replace(key, t)
+	case map[string]any:
+		for k, child := range t {
+			t[k], err = in.walk(k, child)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case map[any]any:
+		for k, child := range t {
+			ks, _ := k.(string)
+			t[k], err = in.walk(ks, child)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, child := range t {
+			t[i], err = in.walk(key, child)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// isJSON returns true if s looks like a JSON document rather than YAML
+func isJSON(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "{")
+}
+
+// Instantiate fills in the placeholders of a JSON or YAML workout template and
+// returns the resulting Workout. All placeholders without a default value are
+// required, and every parameter must be used by the template.
+func Instantiate(template string, params map[string]any) (Workout, error) {
+	in := instantiator{params: params, used: map[string]bool{}}
+
+	var tree any
+	if isJSON(template) {
+		d := json.NewDecoder(strings.NewReader(template))
+		d.UseNumber()
+		err := d.Decode(&tree)
+		if err != nil {
+			return Workout{}, err
+		}
+	} else {
+		err := yaml.Unmarshal([]byte(template), &tree)
+		if err != nil {
+			return Workout{}, err
+		}
+	}
+
+	tree, err := in.walk("", tree)
+	if err != nil {
+		return Workout{}, err
+	}
+
+	var unused []string
+	for name := range params {
+		if !in.used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return Workout{}, fmt.Errorf("Template does not use parameters %v", unused)
+	}
+
+	if isJSON(template) {
+		var buf bytes.Buffer
+		err = json.NewEncoder(&buf).Encode(tree)
+		if err != nil {
+			return Workout{}, err
+		}
+		return FromJSON(buf.String())
+	}
+	s, err := yaml.Marshal(tree)
+	if err != nil {
+		return Workout{}, err
+	}
+	return FromYAML(string(s))
+}
+
+// InstantiateFile reads a workout template from file f and instantiates it
+// with params
+func InstantiateFile(f string, params map[string]any) (Workout, error) {
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return Workout{}, err
+	}
+	return Instantiate(string(data), params)
+}
+
+// TemplateParameters returns the names of the placeholders in a template and
+// whether each of them is required
+func TemplateParameters(template string) map[string]bool {
+	params := map[string]bool{}
+	for _, m := range placeholderRe.FindAllStringSubmatch(template, -1) {
+		required := !strings.Contains(m[0], ":-")
+		params[m[1]] = params[m[1]] || required
+	}
+	return params
+}
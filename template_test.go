@@ -0,0 +1,115 @@
+package goworkouts
+
+import (
+	"testing"
+	"time"
+)
+
+const jsonTemplate = `{"workoutName": "${reps}x${work_time}", "sport": "${sport:-rowing}", "steps": [
+	{"stepId": 0, "wkt_step_name": "work", "durationType": "Time", "durationValue": "${work_time}",
+	 "targetType": "Power", "targetValue": "${zone:-4}", "intensity": "Active"},
+	{"stepId": 1, "wkt_step_name": "rest", "durationType": "Time", "durationValue": 60000, "intensity": "Rest"},
+	{"stepId": 2, "durationType": "RepeatUntilStepsCmplt", "durationValue": 0, "targetValue": "${reps}"}]}`
+
+const yamlTemplate = `workoutName: ${reps}x500m
+sport: rowing
+steps:
+- stepId: 0
+  durationType: Distance
+  durationValue: ${distance:-50000}
+  intensity: Active
+  description: ${note}
+- stepId: 1
+  durationType: RepeatUntilStepsCmplt
+  durationValue: 0
+  targetValue: ${reps}
+`
+
+func TestInstantiateJSON(t *testing.T) {
+	w, err := Instantiate(jsonTemplate, map[string]any{"reps": 5, "work_time": 3 * time.Minute})
+	if err != nil {
+		t.Fatalf("Instantiate returned an error: %v", err)
+	}
+	if w.Name != "5x3m0s" {
+		t.Errorf("Wanted name 5x3m0s, got %v", w.Name)
+	}
+	if w.Sport != "rowing" {
+		t.Errorf("Default sport not used")
+	}
+	if w.Steps[0].DurationValue != 180000 {
+		t.Errorf("Wanted duration 180000, got %v", w.Steps[0].DurationValue)
+	}
+	if w.Steps[0].TargetValue != 4 {
+		t.Errorf("Wanted zone 4, got %v", w.Steps[0].TargetValue)
+	}
+	if w.Steps[2].TargetValue != 5 {
+		t.Errorf("Wanted 5 repeats, got %v", w.Steps[2].TargetValue)
+	}
+
+	_, err = Instantiate(jsonTemplate, map[string]any{"reps": 5})
+	if err == nil {
+		t.Errorf("Missing parameter should have thrown an error")
+	}
+	_, err = Instantiate(jsonTemplate, map[string]any{"reps": "five", "work_time": 180000})
+	if err == nil {
+		t.Errorf("Wrong parameter type should have thrown an error")
+	}
+	_, err = Instantiate(jsonTemplate, map[string]any{"reps": -1, "work_time": 180000})
+	if err == nil {
+		t.Errorf("Negative parameter should have thrown an error")
+	}
+	_, err = Instantiate(jsonTemplate, map[string]any{"reps": 5, "work_time": 180000, "rest": 1})
+	if err == nil {
+		t.Errorf("Unused parameter should have thrown an error")
+	}
+}
+
+func TestInstantiatePoolLength(t *testing.T) {
+	template := `{"workoutName": "Swim", "sport": "swimming", "poolLength": "${pool}", "poolLengthUnit": "Metric",
+	"capabilities": "${capabilities:-0}", "steps": [{"stepId": 0, "durationType": "Distance", "durationValue": "${distance}", "intensity": "Active"}]}`
+	w, err := Instantiate(template, map[string]any{"pool": 25, "distance": 40000})
+	if err != nil {
+		t.Fatalf("Instantiate returned an error: %v", err)
+	}
+	if w.PoolLength != 25 || w.Steps[0].DurationValue != 40000 {
+		t.Errorf("Wrong workout %+v", w)
+	}
+	w, err = Instantiate(template, map[string]any{"pool": 33.33, "distance": 40000})
+	if err != nil || w.PoolLength != 33.33 {
+		t.Errorf("Got pool length %v, %v, wanted 33.33", w.PoolLength, err)
+	}
+	_, err = Instantiate(template, map[string]any{"pool": -25, "distance": 40000})
+	if err == nil {
+		t.Errorf("Negative pool length should have thrown an error")
+	}
+
+	for _, key := range []string{"poolLength", "capabilities", "startOffset", "schemaVersion", "durationValue", "order"} {
+		if _, ok := numericFields[key]; !ok {
+			t.Errorf("%v is not a numeric field", key)
+		}
+	}
+	if _, ok := numericFields["workoutName"]; ok {
+		t.Errorf("workoutName is a numeric field")
+	}
+}
+
+func TestInstantiateYAML(t *testing.T) {
+	w, err := Instantiate(yamlTemplate, map[string]any{"reps": 8, "note": "Hard"})
+	if err != nil {
+		t.Fatalf("Instantiate returned an error: %v", err)
+	}
+	if w.Name != "8x500m" {
+		t.Errorf("Wanted name 8x500m, got %v", w.Name)
+	}
+	if w.Steps[0].DurationValue != 50000 || w.Steps[0].Notes != "Hard" {
+		t.Errorf("Placeholders not replaced: %+v", w.Steps[0])
+	}
+	if w.Steps[1].TargetValue != 8 {
+		t.Errorf("Wanted 8 repeats, got %v", w.Steps[1].TargetValue)
+	}
+
+	params := TemplateParameters(yamlTemplate)
+	if len(params) != 3 || !params["reps"] || params["distance"] {
+		t.Errorf("Wrong template parameters %v", params)
+	}
+}
@@ -0,0 +1,775 @@
+package goworkouts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tormoder/fit"
+)
+
+// TrainingPeaks stores the steps of a structured workout in a structure of
+// steps and repetitions. All intensity targets of a workout are percentages
+// of one threshold, the primary intensity metric. Repetitions cannot be
+// nested.
+
+// TPLength is the length of a TrainingPeaks step in seconds or meters, or the
+// number of times a repetition is done
+type TPLength struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"` // second, meter or repetition
+}
+
+// TPTarget is the target range of a TrainingPeaks step. Targets without a
+// unit are in the primary intensity metric of the workout, cadence targets
+// have unit roundOrStridePerMinute.
+type TPTarget struct {
+	MinValue float64 `json:"minValue"`
+	MaxValue float64 `json:"maxValue,omitempty"`
+	Unit     string  `json:"unit,omitempty"`
+}
+
+// TPStep is a step of a TrainingPeaks structure. The items of the structure
+// itself have Type step or repetition and hold their steps in Steps.
+type TPStep struct {
+	Type           string     `json:"type,omitempty"`
+	Name           string     `json:"name,omitempty"`
+	Length         TPLength   `json:"length"`
+	Steps          []TPStep   `json:"steps,omitempty"`
+	Targets        []TPTarget `json:"targets,omitempty"`
+	IntensityClass string     `json:"intensityClass,omitempty"` // warmUp, active, rest or coolDown
+	OpenDuration   bool       `json:"openDuration,omitempty"`
+}
+
+// TPStructure is a TrainingPeaks structured workout
+type TPStructure struct {
+	Structure              []TPStep `json:"structure"`
+	PrimaryLengthMetric    string   `json:"primaryLengthMetric"`    // duration or distance
+	PrimaryIntensityMetric string   `json:"primaryIntensityMetric"` // percentOfFtp, percentOfThresholdHr or percentOfThresholdPace
+}
+
+// UnmarshalJSON also reads a structure that is stored as a JSON string, as
+// some TrainingPeaks exports do
+func (s *TPStructure) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		text, err := strconv.Unquote(string(data))
+		if err != nil {
+			return err
+		}
+		data = []byte(text)
+	}
+	type plain TPStructure
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// TPWorkout is a TrainingPeaks workout. The workouts of a plan export have
+// the date of the workout in WorkoutDay.
+type TPWorkout struct {
+	Title              string       `json:"title"`
+	WorkoutTypeValueID int          `json:"workoutTypeValueId"`
+	WorkoutDay         string       `json:"workoutDay,omitempty"` // like 2024-04-29T00:00:00
+	Description        string       `json:"description,omitempty"`
+	Structure          *TPStructure `json:"structure,omitempty"`
+}
+
+const (
+	tpPercentOfFTP           = "percentOfFtp"
+	tpPercentOfThresholdHR   = "percentOfThresholdHr"
+	tpPercentOfThresholdPace = "percentOfThresholdPace"
+	tpPercentOfMaxHR         = "percentOfMaxHr"
+	tpCadenceUnit            = "roundOrStridePerMinute"
+	tpDayLayout              = "2006-01-02T15:04:05"
+)
+
+// tpSports maps goworkouts sport names to TrainingPeaks workout types
+var tpSports = map[string]int{
+	"swimming":        1,
+	"cycling":         2,
+	"running":         3,
+	"crosscountryski": 11,
+	"rowing":          12,
+	"walking":         13,
+	"generic":         100,
+}
+
+// tpSport returns the sport of a TrainingPeaks workout type
+func tpSport(typeID int) string {
+	switch typeID {
+	case 8: // mountain bike
+		return "cycling"
+	case 9, 10: // strength, custom
+		return "generic"
+	}
+	for name, id := range tpSports {
+		if id == typeID {
+			return name
+		}
+	}
+	return "generic"
+}
+
+var tpIntensities = map[string]string{
+	"Warmup":   "warmUp",
+	"Active":   "active",
+	"Rest":     "rest",
+	"Cooldown": "coolDown",
+}
+
+// tpWriter converts the steps of a workout and notes what it loses
+type tpWriter struct {
+	steps   []WorkoutStep
+	targets []int
+	metric  string
+	profile AthleteProfile
+	report  *FidelityReport
+	written int // steps written so far
+}
+
+// blockEnd returns the position of the repeat step of the outermost block
+// that starts at i and ends before hi, or -1 if no block starts at i
+func blockEnd(targets []int, i, hi int) int {
+	for r := hi - 1; r > i; r-- {
+		if targets[r] == i {
+			return r
+		}
+	}
+	return -1
+}
+
+// thresholdRatio returns the threshold heart rate as a fraction of max HR,
+// 0.9 if the profile does not have both
+func (p AthleteProfile) thresholdRatio() float64 {
+	if p.MaxHR > 0 && p.ThresholdHR > 0 {
+		return p.ThresholdHR / p.MaxHR
+	}
+	return 0.9
+}
+
+// hrThresholdRange returns the heart rate target range of a step as fractions
+// of the threshold heart rate
+func (p AthleteProfile) hrThresholdRange(step WorkoutStep) (float64, float64, bool) {
+	if step.TargetValue == 0 && step.CustomTargetValueHigh > hrOffset {
+		thr := p.thresholdHR()
+		if thr <= 0 {
+			return 0, 0, false
+		}
+		low := float64(step.CustomTargetValueLow) - hrOffset
+		high := float64(step.CustomTargetValueHigh) - hrOffset
+		return low / thr, high / thr, true
+	}
+	low, high, ok := p.hrRange(step)
+	if !ok {
+		return 0, 0, false
+	}
+	ratio := p.thresholdRatio()
+	return low / ratio, high / ratio, true
+}
+
+// tpMetric returns the intensity metric most targets of the steps can be
+// written in
+func tpMetric(steps []WorkoutStep) string {
+	power, hr, speed := 0, 0, 0
+	for _, step := range steps {
+		switch {
+		case isPowerTarget(step.TargetType):
+			power++
+		case isHRTarget(step.TargetType):
+			hr++
+		case isSpeedTarget(step.TargetType):
+			speed++
+		}
+	}
+	switch {
+	case hr > power && hr >= speed:
+		return tpPercentOfThresholdHR
+	case speed > power && speed > hr:
+		return tpPercentOfThresholdPace
+	}
+	return tpPercentOfFTP
+}
+
+// tpTarget returns the target of a TrainingPeaks step with a range of
+// fractions of a threshold
+func tpTarget(low, high float64) TPTarget {
+	return TPTarget{MinValue: math.Round(low * 100), MaxValue: math.Round(high * 100)}
+}
+
+// target returns the targets of the TrainingPeaks step of step i
+func (tw *tpWriter) target(i int, step WorkoutStep) []TPTarget {
+	var low, high float64
+	ok := false
+	metric := ""
+	switch {
+	case step.TargetType == "Open":
+		return nil
+	case step.TargetType == "Cadence":
+		if step.TargetValue > 0 {
+			tw.report.add(i, "TargetValue", LossRewritten, "TrainingPeaks has no cadence zones, the value is written as a range")
+			return []TPTarget{{MinValue: float64(step.TargetValue), MaxValue: float64(step.TargetValue), Unit: tpCadenceUnit}}
+		}
+		return []TPTarget{{MinValue: float64(step.CustomTargetValueLow), MaxValue: float64(step.CustomTargetValueHigh), Unit: tpCadenceUnit}}
+	case isPowerTarget(step.TargetType):
+		metric = tpPercentOfFTP
+		low, high, ok = tw.profile.powerRange(step)
+	case isHRTarget(step.TargetType):
+		metric = tpPercentOfThresholdHR
+		low, high, ok = tw.profile.hrThresholdRange(step)
+	case isSpeedTarget(step.TargetType):
+		metric = tpPercentOfThresholdPace
+		if step.TargetValue == 0 && tw.profile.ThresholdSpeed > 0 {
+			low = float64(step.CustomTargetValueLow) / 1000 / tw.profile.ThresholdSpeed
+			high = float64(step.CustomTargetValueHigh) / 1000 / tw.profile.ThresholdSpeed
+			ok = high > 0
+		}
+	default:
+		tw.report.add(i, "TargetType", LossDropped, "TrainingPeaks has no %v target", step.TargetType)
+		return nil
+	}
+	if metric != tw.metric {
+		tw.report.add(i, "TargetType", LossDropped, "TrainingPeaks workouts have targets in %v only", tw.metric)
+		return nil
+	}
+	if !ok {
+		tw.report.add(i, "TargetType", LossDropped, "The %v target cannot be written in %v with this athlete profile", step.TargetType, metric)
+		return nil
+	}
+	switch {
+	case step.TargetValue > 0:
+		tw.report.add(i, "TargetValue", LossApproximated, "TrainingPeaks has no zones, zone %d is written as its range", step.TargetValue)
+	case isHRTarget(step.TargetType):
+		tw.report.add(i, "TargetValue", LossApproximated, "Heart rate is written as percent of threshold heart rate")
+	case isPowerTarget(step.TargetType) && step.CustomTargetValueHigh > powerOffset:
+		tw.report.add(i, "TargetValue", LossApproximated, "Power in W is written as percent of FTP")
+	case isSpeedTarget(step.TargetType):
+		tw.report.add(i, "TargetValue", LossApproximated, "Speed is written as percent of threshold speed")
+	}
+	if step.TargetType != "Power" && step.TargetType != "HeartRate" && step.TargetType != "Speed" {
+		tw.report.add(i, "TargetType", LossRewritten, "TrainingPeaks has no %v target", step.TargetType)
+	}
+	return []TPTarget{tpTarget(low, high)}
+}
+
+// step returns the TrainingPeaks step of step i
+func (tw *tpWriter) step(i int) TPStep {
+	step := tw.steps[i]
+	tp := TPStep{Type: "step", Name: step.WktStepName}
+	if step.Notes != "" {
+		tw.report.add(i, "Notes", LossDropped, "TrainingPeaks steps have no notes")
+	}
+
+	intensity, ok := tpIntensities[step.Intensity]
+	if !ok {
+		intensity = "active"
+		if step.Intensity == "Recovery" {
+			intensity = "rest"
+		}
+		tw.report.add(i, "Intensity", LossRewritten, "TrainingPeaks has no %v steps, it is written as %v", step.Intensity, intensity)
+	}
+	tp.IntensityClass = intensity
+
+	switch step.DurationType {
+	case "Time":
+		tp.Length = TPLength{Value: float64(step.DurationValue) / 1000, Unit: "second"}
+	case "Distance":
+		tp.Length = TPLength{Value: float64(step.DurationValue) / 100, Unit: "meter"}
+	case "Open":
+		tp.Length = TPLength{Unit: "second"}
+		tp.OpenDuration = true
+	default:
+		tw.report.add(i, "DurationType", LossDropped, "TrainingPeaks has no %v steps, the step is written as open", step.DurationType)
+		tp.Length = TPLength{Unit: "second"}
+		tp.OpenDuration = true
+	}
+
+	tp.Targets = tw.target(i, step)
+	return tp
+}
+
+// checkRepeat reports what a repeat step loses
+func (tw *tpWriter) checkRepeat(i int) {
+	step := tw.steps[i]
+	if step.DurationType != "RepeatUntilStepsCmplt" {
+		tw.report.add(i, "DurationType", LossRewritten, "TrainingPeaks only repeats a number of times, %v is written as a single pass", step.DurationType)
+	}
+	fields := []struct {
+		name string
+		set  bool
+	}{
+		{"WktStepName", step.WktStepName != ""},
+		{"Notes", step.Notes != ""},
+		{"TargetType", step.TargetType != "Open"},
+		{"Intensity", step.Intensity != "Active"},
+	}
+	for _, field := range fields {
+		if field.set {
+			tw.report.add(i, field.name, LossDropped, "TrainingPeaks repetitions have no %v", field.name)
+		}
+	}
+}
+
+// count adds n written steps and returns an error once there are more than
+// maxExpandedSteps, like Expand
+func (tw *tpWriter) count(n uint64) error {
+	if n > maxExpandedSteps || tw.written+int(n) > maxExpandedSteps {
+		return fmt.Errorf("Workout has more than %d steps when repeats are expanded", maxExpandedSteps)
+	}
+	tw.written += int(n)
+	return nil
+}
+
+// expand returns the TrainingPeaks steps of the steps at positions lo to hi
+// (exclusive), with the repeats written out
+func (tw *tpWriter) expand(lo, hi int) ([]TPStep, error) {
+	var out []TPStep
+	for i := lo; i < hi; {
+		end := blockEnd(tw.targets, i, hi)
+		if end < 0 {
+			err := tw.count(1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, tw.step(i))
+			i++
+			continue
+		}
+		tw.checkRepeat(end)
+		count := repeatCount(tw.steps[end])
+		tw.report.add(end, "DurationType", LossRewritten, "TrainingPeaks cannot nest repetitions, the steps are written out %d times", count)
+		block, err := tw.expand(i, end)
+		if err != nil {
+			return nil, err
+		}
+		// the block is counted once by expand
+		err = tw.count(uint64(len(block)) * uint64(count-1))
+		if err != nil {
+			return nil, err
+		}
+		for n := uint32(0); n < count; n++ {
+			out = append(out, block...)
+		}
+		i = end + 1
+	}
+	return out, nil
+}
+
+// build returns the structure of the steps. A block becomes a repetition,
+// the other steps a step with a single repetition.
+func (tw *tpWriter) build() ([]TPStep, error) {
+	var out []TPStep
+	for i := 0; i < len(tw.steps); {
+		end := blockEnd(tw.targets, i, len(tw.steps))
+		if end < 0 {
+			out = append(out, TPStep{
+				Type:   "step",
+				Length: TPLength{Value: 1, Unit: "repetition"},
+				Steps:  []TPStep{tw.step(i)},
+			})
+			i++
+			continue
+		}
+		tw.checkRepeat(end)
+		steps, err := tw.expand(i, end)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, TPStep{
+			Type:   "repetition",
+			Length: TPLength{Value: float64(repeatCount(tw.steps[end])), Unit: "repetition"},
+			Steps:  steps,
+		})
+		i = end + 1
+	}
+	return out, nil
+}
+
+// toTrainingPeaks converts the workout and reports what is lost on the way
+func (w *Workout) toTrainingPeaks(profile AthleteProfile) (TPWorkout, FidelityReport, error) {
+	report := FidelityReport{Format: "trainingpeaks"}
+	targets, err := repeatTargets(w.Steps)
+	if err != nil {
+		return TPWorkout{}, report, err
+	}
+	err = checkNesting(w.Steps, targets)
+	if err != nil {
+		return TPWorkout{}, report, err
+	}
+
+	typeID, ok := tpSports[w.Sport]
+	if !ok {
+		typeID = tpSports["generic"]
+		if w.Sport != "" {
+			report.add(-1, "Sport", LossRewritten, "TrainingPeaks has no %v workouts, it is written as other", w.Sport)
+		}
+	}
+	workoutFields := []struct {
+		name string
+		set  bool
+	}{
+		{"SubSport", w.SubSport != ""},
+		{"PoolLength", w.PoolLength != 0},
+		{"Capabilities", w.Capabilities != 0},
+		{"Extensions", !w.Extensions.empty()},
+	}
+	for _, field := range workoutFields {
+		if field.set {
+			report.add(-1, field.name, LossDropped, "TrainingPeaks workouts have no %v", field.name)
+		}
+	}
+	distance := 0
+	for i, step := range w.Steps {
+		if int(step.MessageIndex) != i {
+			report.add(i, "MessageIndex", LossRewritten, "TrainingPeaks numbers the steps in order")
+		}
+		if !step.Extensions.empty() {
+			report.add(i, "Extensions", LossDropped, "TrainingPeaks steps have no FIT extensions")
+		}
+		switch step.DurationType {
+		case "Distance":
+			distance++
+		case "Time":
+			distance--
+		}
+	}
+
+	tw := tpWriter{
+		steps:   w.Steps,
+		targets: targets,
+		metric:  tpMetric(w.Steps),
+		profile: profile,
+		report:  &report,
+	}
+	steps, err := tw.build()
+	if err != nil {
+		return TPWorkout{}, report, err
+	}
+	structure := TPStructure{
+		Structure:              steps,
+		PrimaryLengthMetric:    "duration",
+		PrimaryIntensityMetric: tw.metric,
+	}
+	if distance > 0 {
+		structure.PrimaryLengthMetric = "distance"
+	}
+	return TPWorkout{
+		Title:              w.Name,
+		WorkoutTypeValueID: typeID,
+		Description:        w.Description,
+		Structure:          &structure,
+	}, report, nil
+}
+
+// ToTrainingPeaksJSON exports to a TrainingPeaks structured workout. Targets
+// are written as percentages of the thresholds of profile: power targets as
+// percent of FTP, heart rate targets as percent of threshold heart rate and
+// speed targets as percent of threshold speed. Use
+// Fidelity(w, "trainingpeaks") to find out what it loses with an empty
+// profile.
+func (w *Workout) ToTrainingPeaksJSON(profile AthleteProfile) ([]byte, error) {
+	tp, _, err := w.toTrainingPeaks(profile)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tp)
+}
+
+// tpDuration sets the duration of a step from a TrainingPeaks length
+func tpDuration(step *WorkoutStep, s TPStep) error {
+	if s.OpenDuration {
+		step.DurationType = "Open"
+		return nil
+	}
+	scale := 0.0
+	switch s.Length.Unit {
+	case "second":
+		step.DurationType, scale = "Time", 1000
+	case "minute":
+		step.DurationType, scale = "Time", 60000
+	case "hour":
+		step.DurationType, scale = "Time", 3600000
+	case "meter":
+		step.DurationType, scale = "Distance", 100
+	case "kilometer":
+		step.DurationType, scale = "Distance", 100000
+	case "mile":
+		step.DurationType, scale = "Distance", 160934.4
+	default:
+		return fmt.Errorf("Unknown TrainingPeaks length unit %q", s.Length.Unit)
+	}
+	step.DurationValue = uint32(math.Round(s.Length.Value * scale))
+	return nil
+}
+
+// tpRange returns the low and high value of a target. A target without a
+// maximum is a single value.
+func tpRange(t TPTarget) (float64, float64) {
+	if t.MaxValue == 0 {
+		return t.MinValue, t.MinValue
+	}
+	return math.Min(t.MinValue, t.MaxValue), math.Max(t.MinValue, t.MaxValue)
+}
+
+// tpSetTarget sets the target of a step from a TrainingPeaks target in
+// metric. FIT steps have a single target, so an intensity target wins over
+// a cadence target.
+func tpSetTarget(step *WorkoutStep, targets []TPTarget, metric string, profile AthleteProfile) error {
+	step.TargetType = "Open"
+	for _, t := range targets {
+		if t.Unit == tpCadenceUnit && step.TargetType == "Open" {
+			low, high := tpRange(t)
+			step.TargetType = "Cadence"
+			step.CustomTargetValueLow, step.CustomTargetValueHigh = uint32(math.Round(low)), uint32(math.Round(high))
+		}
+	}
+	for _, t := range targets {
+		if t.Unit != "" || (t.MinValue == 0 && t.MaxValue == 0) {
+			continue
+		}
+		low, high := tpRange(t)
+		scale := 1.0
+		switch metric {
+		case tpPercentOfFTP:
+			step.TargetType = "Power"
+		case tpPercentOfThresholdHR:
+			step.TargetType, scale = "HeartRate", profile.thresholdRatio()
+		case tpPercentOfMaxHR:
+			step.TargetType = "HeartRate"
+		case tpPercentOfThresholdPace:
+			if profile.ThresholdSpeed <= 0 {
+				return errors.New("Need a threshold speed for percentOfThresholdPace targets")
+			}
+			step.TargetType, scale = "Speed", profile.ThresholdSpeed*10
+		default:
+			return fmt.Errorf("Unknown TrainingPeaks intensity metric %q", metric)
+		}
+		step.TargetValue = 0
+		step.CustomTargetValueLow = uint32(math.Round(low * scale))
+		step.CustomTargetValueHigh = uint32(math.Round(high * scale))
+		return nil
+	}
+	return nil
+}
+
+// fromTPStep converts a TrainingPeaks step
+func fromTPStep(s TPStep, idx int, metric string, profile AthleteProfile) (WorkoutStep, error) {
+	step := newWorkoutStep()
+	step.MessageIndex = fit.MessageIndex(idx)
+	step.WktStepName = s.Name
+
+	switch s.IntensityClass {
+	case "", "active":
+		step.Intensity = "Active"
+	case "warmUp":
+		step.Intensity = "Warmup"
+	case "coolDown":
+		step.Intensity = "Cooldown"
+	case "rest":
+		step.Intensity = "Rest"
+	case "recovery":
+		step.Intensity = "Recovery"
+	default:
+		return step, fmt.Errorf("Unknown TrainingPeaks intensity class %q", s.IntensityClass)
+	}
+
+	err := tpDuration(&step, s)
+	if err != nil {
+		return step, err
+	}
+	err = tpSetTarget(&step, s.Targets, metric, profile)
+	return step, err
+}
+
+// appendTPSteps appends the FIT steps of TrainingPeaks steps to steps. A
+// repetition becomes its steps followed by a repeat step, the steps of a
+// step with a single repetition are added as they are.
+func appendTPSteps(steps []WorkoutStep, items []TPStep, metric string, profile AthleteProfile) ([]WorkoutStep, error) {
+	for _, s := range items {
+		if len(s.Steps) == 0 {
+			step, err := fromTPStep(s, len(steps), metric, profile)
+			if err != nil {
+				return nil, &StepError{Index: len(steps), Err: err}
+			}
+			steps = append(steps, step)
+			continue
+		}
+		count := 1
+		if s.Length.Unit == "repetition" {
+			count = int(math.Round(s.Length.Value))
+		}
+		first := len(steps)
+		var err error
+		steps, err = appendTPSteps(steps, s.Steps, metric, profile)
+		if err != nil {
+			return nil, err
+		}
+		if count < 1 || (count == 1 && s.Type != "repetition") {
+			continue
+		}
+		repeat := newWorkoutStep()
+		repeat.MessageIndex = fit.MessageIndex(len(steps))
+		repeat.DurationType = "RepeatUntilStepsCmplt"
+		repeat.DurationValue = uint32(first)
+		repeat.TargetType = "Open"
+		repeat.TargetValue = uint32(count)
+		repeat.Intensity = "Active"
+		steps = append(steps, repeat)
+	}
+	return steps, nil
+}
+
+// Workout returns the TrainingPeaks workout as a goworkouts workout. Targets
+// in percent of threshold speed need the threshold speed of profile, targets
+// in percent of threshold heart rate use the ratio of threshold and max heart
+// rate of profile, or 0.9.
+func (tp *TPWorkout) Workout(profile AthleteProfile) (Workout, error) {
+	if tp.Structure == nil {
+		return Workout{}, errors.New("Workout has no structured steps")
+	}
+	steps, err := appendTPSteps(nil, tp.Structure.Structure, tp.Structure.PrimaryIntensityMetric, profile)
+	if err != nil {
+		return Workout{}, err
+	}
+	return Workout{
+		Name:        tp.Title,
+		Sport:       tpSport(tp.WorkoutTypeValueID),
+		Description: tp.Description,
+		Steps:       steps,
+	}, nil
+}
+
+// FromTrainingPeaksJSON returns workout from a TrainingPeaks structured
+// workout, see TPWorkout.Workout
+func FromTrainingPeaksJSON(s string, profile AthleteProfile) (Workout, error) {
+	var tp TPWorkout
+	err := json.Unmarshal([]byte(s), &tp)
+	if err != nil {
+		return Workout{}, err
+	}
+	return tp.Workout(profile)
+}
+
+// ToTrainingPeaks returns the workouts of the plan as TrainingPeaks workouts,
+// with day 1 of the plan on the date of start
+func (p *TrainingPlan) ToTrainingPeaks(start time.Time, profile AthleteProfile) ([]TPWorkout, error) {
+	var out []TPWorkout
+	day1 := localDate(start)
+	for _, day := range p.TrainingDays {
+		date := day1.AddDate(0, 0, int(day.Order)-1)
+		for j := range day.Workouts {
+			tp, _, err := day.Workouts[j].toTrainingPeaks(profile)
+			if err != nil {
+				return nil, fmt.Errorf("Day %d, workout %d: %w", day.Order, j, err)
+			}
+			tp.WorkoutDay = date.Format(tpDayLayout)
+			out = append(out, tp)
+		}
+	}
+	return out, nil
+}
+
+// tpDay returns the date of a TrainingPeaks workout
+func tpDay(s string) (time.Time, error) {
+	if len(s) == len("2006-01-02") {
+		return time.Parse("2006-01-02", s)
+	}
+	return time.Parse(tpDayLayout, s)
+}
+
+// TrainingPlanFromTrainingPeaks returns a plan with the workouts of a
+// TrainingPeaks plan export. The date of the first workout is day 1, workouts
+// on the same date are kept in the order they come in. Workouts without
+// structured steps, like days off, are left out.
+func TrainingPlanFromTrainingPeaks(workouts []TPWorkout, profile AthleteProfile) (TrainingPlan, error) {
+	type dated struct {
+		date    time.Time
+		workout Workout
+	}
+	var all []dated
+	for i := range workouts {
+		if workouts[i].Structure == nil {
+			continue
+		}
+		date, err := tpDay(workouts[i].WorkoutDay)
+		if err != nil {
+			return TrainingPlan{}, fmt.Errorf("Workout %d: %w", i, err)
+		}
+		w, err := workouts[i].Workout(profile)
+		if err != nil {
+			return TrainingPlan{}, fmt.Errorf("Workout %d: %w", i, err)
+		}
+		all = append(all, dated{localDate(date), w})
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].date.Before(all[j].date)
+	})
+
+	plan := TrainingPlan{ID: uuid.New()}
+	for _, d := range all {
+		order := uint32(math.Round(d.date.Sub(all[0].date).Hours()/24)) + 1
+		n := len(plan.TrainingDays)
+		if n == 0 || plan.TrainingDays[n-1].Order != order {
+			plan.TrainingDays = append(plan.TrainingDays, TrainingDay{Order: order})
+			n++
+		}
+		plan.TrainingDays[n-1].Workouts = append(plan.TrainingDays[n-1].Workouts, d.workout)
+		plan.Duration = order
+	}
+	return plan, nil
+}
+
+// FromTrainingPeaksPlanJSON returns a plan from the JSON array of workouts
+// of a TrainingPeaks plan export, see TrainingPlanFromTrainingPeaks
+func FromTrainingPeaksPlanJSON(s string, profile AthleteProfile) (TrainingPlan, error) {
+	var workouts []TPWorkout
+	err := json.Unmarshal([]byte(s), &workouts)
+	if err != nil {
+		return TrainingPlan{}, err
+	}
+	return TrainingPlanFromTrainingPeaks(workouts, profile)
+}
+
+// trainingPeaksFormat is a TrainingPeaks structured workout. It reads and
+// writes with an empty athlete profile.
+type trainingPeaksFormat struct{}
+
+func (trainingPeaksFormat) Name() string         { return "trainingpeaks" }
+func (trainingPeaksFormat) Extensions() []string { return []string{".json"} }
+
+func (trainingPeaksFormat) Detect(data []byte) bool {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) != nil {
+		return false
+	}
+	_, ok := object["structure"]
+	return ok
+}
+
+func (trainingPeaksFormat) Decode(r io.Reader) (Workout, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Workout{}, err
+	}
+	return FromTrainingPeaksJSON(string(data), AthleteProfile{})
+}
+
+func (trainingPeaksFormat) Encode(wr io.Writer, w *Workout) error {
+	data, err := w.ToTrainingPeaksJSON(AthleteProfile{})
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(data)
+	return err
+}
+
+// Fidelity is empty for workouts with invalid repeats, which cannot be
+// exported at all
+func (trainingPeaksFormat) Fidelity(w *Workout) FidelityReport {
+	_, report, _ := w.toTrainingPeaks(AthleteProfile{})
+	return report
+}
@@ -0,0 +1,246 @@
+package goworkouts
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestTrainingPeaksRoundTrip converts every FIT workout in testdata to
+// TrainingPeaks JSON and back. The steps must come back the same, except for
+// the fields the fidelity report lists. Nested repeats are written out, so
+// for those only the planned duration is compared.
+func TestTrainingPeaksRoundTrip(t *testing.T) {
+	paths, _ := filepath.Glob("testdata/*.fit")
+	sdk, _ := filepath.Glob("testdata/fitsdk/*.fit")
+	for _, path := range append(paths, sdk...) {
+		if !isWorkoutFile(path) {
+			continue
+		}
+		t.Run(goldenName(path), func(t *testing.T) {
+			w, err := ReadFit(path)
+			if err != nil {
+				t.Fatalf("ReadFit returned an error: %v", err)
+			}
+			data, err := w.ToTrainingPeaksJSON(AthleteProfile{})
+			if err != nil {
+				t.Fatalf("ToTrainingPeaksJSON returned an error: %v", err)
+			}
+			back, err := FromTrainingPeaksJSON(string(data), AthleteProfile{})
+			if err != nil {
+				t.Fatalf("FromTrainingPeaksJSON returned an error: %v", err)
+			}
+			report, err := Fidelity(&w, "trainingpeaks")
+			if err != nil {
+				t.Fatalf("Fidelity returned an error: %v", err)
+			}
+
+			want := fitSteps(t, w)
+			got := fitSteps(t, back)
+			if len(got) != len(want) {
+				d1, err1 := w.PlannedDuration(0)
+				d2, err2 := back.PlannedDuration(0)
+				if err1 != nil || err2 != nil || d1 != d2 {
+					t.Errorf("Got %d steps and duration %v, wanted %d steps and duration %v", len(got), d2, len(want), d1)
+				}
+				return
+			}
+			clearLost(want, report)
+			clearLost(got, report)
+			for i := range want {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Errorf("Step %d differs in a field that is not in the report\nGot:  %+v\nWant: %+v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrainingPeaksJSON(t *testing.T) {
+	w := Workout{
+		Name:        "Threshold",
+		Sport:       "cycling",
+		Description: "Over-unders",
+		Steps: []WorkoutStep{
+			{MessageIndex: 0, WktStepName: "Warm up", DurationType: "Time", DurationValue: 600000, TargetType: "Power", CustomTargetValueLow: 50, CustomTargetValueHigh: 70, Intensity: "Warmup"},
+			{MessageIndex: 1, WktStepName: "Over", DurationType: "Time", DurationValue: 120000, TargetType: "Power", CustomTargetValueLow: 105, CustomTargetValueHigh: 110, Intensity: "Active"},
+			{MessageIndex: 2, WktStepName: "Spin", DurationType: "Distance", DurationValue: 50000, TargetType: "Cadence", CustomTargetValueLow: 95, CustomTargetValueHigh: 105, Intensity: "Rest"},
+			{MessageIndex: 3, DurationType: "RepeatUntilStepsCmplt", DurationValue: 1, TargetType: "Open", TargetValue: 4, Intensity: "Active"},
+			{MessageIndex: 4, DurationType: "Open", TargetType: "Open", Intensity: "Cooldown"},
+		},
+	}
+	data, err := w.ToTrainingPeaksJSON(AthleteProfile{})
+	if err != nil {
+		t.Fatalf("ToTrainingPeaksJSON returned an error: %v", err)
+	}
+	checkGoldenJSON(t, "trainingpeaks.json", data)
+
+	var tp TPWorkout
+	err = json.Unmarshal(data, &tp)
+	if err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	structure := tp.Structure.Structure
+	if len(structure) != 3 || structure[1].Type != "repetition" || structure[1].Length.Value != 4 || len(structure[1].Steps) != 2 {
+		t.Fatalf("Wrong structure %+v", structure)
+	}
+	if tp.WorkoutTypeValueID != 2 || tp.Structure.PrimaryIntensityMetric != "percentOfFtp" {
+		t.Errorf("Wrong workout %+v", tp)
+	}
+
+	report, _ := Fidelity(&w, "trainingpeaks")
+	if !report.Lossless() {
+		t.Errorf("Expected a lossless export, got %+v", report.Losses)
+	}
+	back, err := FromTrainingPeaksJSON(string(data), AthleteProfile{})
+	if err != nil {
+		t.Fatalf("FromTrainingPeaksJSON returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(back, w) {
+		t.Errorf("Round trip changed the workout\nGot:  %+v\nWant: %+v", back, w)
+	}
+
+	f, err := DetectFormat(data, "workout.json")
+	if err != nil || f.Name() != "trainingpeaks" {
+		t.Errorf("TrainingPeaks JSON is not detected: %v", err)
+	}
+}
+
+func TestTrainingPeaksTargets(t *testing.T) {
+	profile := AthleteProfile{FTP: 250, MaxHR: 190, ThresholdHR: 171, ThresholdSpeed: 4}
+
+	// heart rate targets as a string, as in some exports
+	s := `{"title":"Tempo","workoutTypeValueId":3,"structure":"{\"primaryIntensityMetric\":\"percentOfThresholdHr\",\"primaryLengthMetric\":\"duration\",\"structure\":[{\"type\":\"step\",\"length\":{\"value\":1,\"unit\":\"repetition\"},\"steps\":[{\"length\":{\"value\":20,\"unit\":\"minute\"},\"targets\":[{\"minValue\":90,\"maxValue\":100}],\"intensityClass\":\"active\"}]}]}"}`
+	w, err := FromTrainingPeaksJSON(s, profile)
+	if err != nil {
+		t.Fatalf("FromTrainingPeaksJSON returned an error: %v", err)
+	}
+	want := WorkoutStep{DurationType: "Time", DurationValue: 1200000, TargetType: "HeartRate", CustomTargetValueLow: 81, CustomTargetValueHigh: 90, Intensity: "Active"}
+	if w.Sport != "running" || len(w.Steps) != 1 || !reflect.DeepEqual(w.Steps[0], want) {
+		t.Errorf("Wrong workout %+v", w)
+	}
+
+	// strength workouts are generic, which FIT knows
+	s = `{"title":"Gym","workoutTypeValueId":9,"structure":{"primaryIntensityMetric":"percentOfFtp","structure":[{"type":"step","length":{"value":1,"unit":"repetition"},"steps":[{"length":{"value":30,"unit":"minute"},"intensityClass":"active"}]}]}}`
+	w, err = FromTrainingPeaksJSON(s, profile)
+	if err != nil {
+		t.Fatalf("FromTrainingPeaksJSON returned an error: %v", err)
+	}
+	if _, ok := sportMapping[w.Sport]; !ok || w.Sport != "generic" {
+		t.Errorf("Got sport %q for a strength workout, wanted generic", w.Sport)
+	}
+
+	// watts and zones are written as percentages
+	w = Workout{Sport: "cycling", Steps: []WorkoutStep{
+		{DurationType: "Time", DurationValue: 60000, TargetType: "Power", CustomTargetValueLow: 1200, CustomTargetValueHigh: 1250, Intensity: "Active"},
+		{MessageIndex: 1, DurationType: "Time", DurationValue: 60000, TargetType: "Power", TargetValue: 2, Intensity: "Active"},
+		{MessageIndex: 2, DurationType: "Time", DurationValue: 60000, TargetType: "HeartRate", TargetValue: 2, Intensity: "Active"},
+	}}
+	tp, report, err := w.toTrainingPeaks(profile)
+	if err != nil {
+		t.Fatalf("toTrainingPeaks returned an error: %v", err)
+	}
+	targets := []TPTarget{
+		tp.Structure.Structure[0].Steps[0].Targets[0],
+		tp.Structure.Structure[1].Steps[0].Targets[0],
+	}
+	if !reflect.DeepEqual(targets, []TPTarget{{80, 100, ""}, {55, 75, ""}}) {
+		t.Errorf("Wrong targets %+v", targets)
+	}
+	if tp.Structure.Structure[2].Steps[0].Targets != nil {
+		t.Errorf("A heart rate target is written in a power workout")
+	}
+	if len(report.Losses) != 3 || report.Losses[2].Kind != LossDropped {
+		t.Errorf("Wrong report %+v", report.Losses)
+	}
+}
+
+func TestTrainingPeaksPlan(t *testing.T) {
+	data, err := ioutil.ReadFile("RowsandallTPdummy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan TrainingPlan
+	err = json.Unmarshal(data, &plan)
+	if err != nil {
+		t.Fatalf("Could not read the plan: %v", err)
+	}
+	start := time.Date(2024, 4, 29, 18, 30, 0, 0, time.Local)
+	workouts, err := plan.ToTrainingPeaks(start, AthleteProfile{})
+	if err != nil {
+		t.Fatalf("ToTrainingPeaks returned an error: %v", err)
+	}
+	days := []string{"2024-04-29T00:00:00", "2024-04-30T00:00:00", "2024-05-02T00:00:00"}
+	for i, tp := range workouts {
+		if tp.WorkoutDay != days[i] {
+			t.Errorf("Workout %d is on %v, wanted %v", i, tp.WorkoutDay, days[i])
+		}
+	}
+
+	// a day off, which is left out
+	workouts = append(workouts, TPWorkout{Title: "Day off", WorkoutTypeValueID: 7, WorkoutDay: "2024-05-03"})
+	data, err = json.Marshal(workouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := FromTrainingPeaksPlanJSON(string(data), AthleteProfile{})
+	if err != nil {
+		t.Fatalf("FromTrainingPeaksPlanJSON returned an error: %v", err)
+	}
+	if back.Duration != plan.Duration || len(back.TrainingDays) != len(plan.TrainingDays) {
+		t.Fatalf("Got duration %d and %d days, wanted %d and %d", back.Duration, len(back.TrainingDays), plan.Duration, len(plan.TrainingDays))
+	}
+	for i, day := range back.TrainingDays {
+		orig := plan.TrainingDays[i]
+		if day.Order != orig.Order || len(day.Workouts) != len(orig.Workouts) {
+			t.Errorf("Day %d is %d with %d workouts, wanted %d with %d", i, day.Order, len(day.Workouts), orig.Order, len(orig.Workouts))
+			continue
+		}
+		for j, w := range day.Workouts {
+			if w.Name != orig.Workouts[j].Name || len(w.Steps) != len(orig.Workouts[j].Steps) {
+				t.Errorf("Wrong workout %d on day %d: %+v", j, day.Order, w)
+			}
+		}
+	}
+}
+
+func TestTrainingPeaksHugeRepeat(t *testing.T) {
+	w := Workout{Sport: "cycling", Steps: []WorkoutStep{
+		{DurationType: "Time", DurationValue: 1000, TargetType: "Open", Intensity: "Active"},
+		{MessageIndex: 1, DurationType: "RepeatUntilStepsCmplt", DurationValue: 0, TargetType: "Open", TargetValue: 2000000000, Intensity: "Active"},
+		{MessageIndex: 2, DurationType: "RepeatUntilStepsCmplt", DurationValue: 0, TargetType: "Open", TargetValue: 2, Intensity: "Active"},
+	}}
+	_, err := w.ToTrainingPeaksJSON(AthleteProfile{})
+	if err == nil {
+		t.Errorf("Expected an error for a nested repeat of 2000000000")
+	}
+	// the report stops at the limit too
+	_, err = Fidelity(&w, "trainingpeaks")
+	if err != nil {
+		t.Errorf("Fidelity returned an error: %v", err)
+	}
+}
+
+func TestTrainingPeaksErrors(t *testing.T) {
+	tests := []string{
+		`{"title":"No steps"}`,
+		`{"structure":{"primaryIntensityMetric":"percentOfFtp","structure":[{"length":{"value":1,"unit":"lap"}}]}}`,
+		`{"structure":{"primaryIntensityMetric":"percentOfFtp","structure":[{"length":{"value":1,"unit":"second"},"intensityClass":"sprint"}]}}`,
+		`{"structure":{"primaryIntensityMetric":"percentOfThresholdPace","structure":[{"length":{"value":1,"unit":"second"},"targets":[{"minValue":90}]}]}}`,
+		`{"structure":{"primaryIntensityMetric":"watts","structure":[{"length":{"value":1,"unit":"second"},"targets":[{"minValue":90}]}]}}`,
+	}
+	for _, test := range tests {
+		_, err := FromTrainingPeaksJSON(test, AthleteProfile{})
+		if err == nil {
+			t.Errorf("Expected an error for %v", test)
+		}
+	}
+
+	_, err := FromTrainingPeaksPlanJSON(`[{"title":"x","workoutDay":"monday","structure":{"structure":[]}}]`, AthleteProfile{})
+	if err == nil {
+		t.Errorf("Expected an error for an invalid date")
+	}
+}
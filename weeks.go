@@ -0,0 +1,207 @@
+package goworkouts
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Weeks of a training plan are numbered from 1. Week n holds the training days
+// with Order 7(n-1)+1 up to 7n, after the StartOffset rest days of the plan.
+// The week and phase descriptions are optional, a plan without them is still
+// divided in weeks by the Order of its days.
+
+// PlanWeek describes a week of a training plan
+type PlanWeek struct {
+	Number   uint32 `json:"number" yaml:"number"`
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Goal     string `json:"goal,omitempty" yaml:"goal,omitempty"`
+	Notes    string `json:"notes,omitempty" yaml:"notes,omitempty"`
+	Recovery bool   `json:"recovery,omitempty" yaml:"recovery,omitempty"`
+}
+
+// PlanPhase is a mesocycle of a training plan, like base or build, covering
+// the weeks FirstWeek up to and including LastWeek
+type PlanPhase struct {
+	Name      string `json:"name" yaml:"name"`
+	FirstWeek uint32 `json:"firstWeek" yaml:"firstWeek"`
+	LastWeek  uint32 `json:"lastWeek" yaml:"lastWeek"`
+	Goal      string `json:"goal,omitempty" yaml:"goal,omitempty"`
+	Notes     string `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
+
+// WeekOf returns the week a day with the given Order is in, in a plan without
+// a start offset
+func WeekOf(order uint32) uint32 {
+	if order == 0 {
+		return 1
+	}
+	return (order-1)/7 + 1
+}
+
+// WeekOf returns the week a day with the given Order is in. The rest days
+// before the first week are in week 1.
+func (p *TrainingPlan) WeekOf(order uint32) uint32 {
+	if order <= p.StartOffset {
+		return 1
+	}
+	return WeekOf(order - p.StartOffset)
+}
+
+// WeekCount returns the number of weeks of the plan, from its duration or
+// the last training day, whichever is later
+func (p *TrainingPlan) WeekCount() uint32 {
+	var weeks uint32
+	if p.Duration > p.StartOffset {
+		weeks = (p.Duration - p.StartOffset + 6) / 7
+	}
+	for _, day := range p.TrainingDays {
+		if p.WeekOf(day.Order) > weeks {
+			weeks = p.WeekOf(day.Order)
+		}
+	}
+	return weeks
+}
+
+// Week returns the description of week n, or a PlanWeek with only the number
+// set if the plan does not describe it
+func (p *TrainingPlan) Week(n uint32) PlanWeek {
+	for _, week := range p.Weeks {
+		if week.Number == n {
+			return week
+		}
+	}
+	return PlanWeek{Number: n}
+}
+
+// Phase returns the phase week n is in
+func (p *TrainingPlan) Phase(n uint32) (PlanPhase, bool) {
+	for _, phase := range p.Phases {
+		if phase.FirstWeek <= n && n <= phase.LastWeek {
+			return phase, true
+		}
+	}
+	return PlanPhase{}, false
+}
+
+// DaysOfWeek returns the training days of week n
+func (p *TrainingPlan) DaysOfWeek(n uint32) []TrainingDay {
+	var days []TrainingDay
+	for _, day := range p.TrainingDays {
+		if p.WeekOf(day.Order) == n {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// DaysByWeek returns the training days of every week of the plan, index 0
+// is week 1
+func (p *TrainingPlan) DaysByWeek() [][]TrainingDay {
+	weeks := make([][]TrainingDay, p.WeekCount())
+	for _, day := range p.TrainingDays {
+		week := p.WeekOf(day.Order) - 1
+		weeks[week] = append(weeks[week], day)
+	}
+	return weeks
+}
+
+func (p *TrainingPlan) checkWeek(n uint32) error {
+	if n < 1 || n > p.WeekCount() {
+		return fmt.Errorf("Week %d out of range", n)
+	}
+	return nil
+}
+
+// renumberWeeks moves every day and week description to the week returned
+// by newWeek and sorts the days
+func (p *TrainingPlan) renumberWeeks(newWeek func(uint32) uint32) {
+	days := make([]TrainingDay, len(p.TrainingDays))
+	for i, day := range p.TrainingDays {
+		old := p.WeekOf(day.Order)
+		day.Order = day.Order + 7*newWeek(old) - 7*old
+		days[i] = day
+	}
+	sort.SliceStable(days, func(a, b int) bool { return days[a].Order < days[b].Order })
+	p.TrainingDays = days
+
+	weeks := make([]PlanWeek, len(p.Weeks))
+	for i, week := range p.Weeks {
+		week.Number = newWeek(week.Number)
+		weeks[i] = week
+	}
+	sort.SliceStable(weeks, func(a, b int) bool { return weeks[a].Number < weeks[b].Number })
+	if len(weeks) > 0 {
+		p.Weeks = weeks
+	}
+}
+
+// MoveWeek moves week from, with its days and description, to week to. The
+// weeks in between shift one week to make room. The phases stay where they
+// are.
+func (p *TrainingPlan) MoveWeek(from, to uint32) error {
+	err := p.checkWeek(from)
+	if err != nil {
+		return err
+	}
+	err = p.checkWeek(to)
+	if err != nil {
+		return err
+	}
+	p.renumberWeeks(func(week uint32) uint32 {
+		switch {
+		case week == from:
+			return to
+		case from < to && from < week && week <= to:
+			return week - 1
+		case to < from && to <= week && week < from:
+			return week + 1
+		}
+		return week
+	})
+	return nil
+}
+
+// InsertRecoveryWeek inserts a recovery week as week n and shifts the later
+// days of the plan by a week. The Order of days is the day of the new week
+// (1-7). The recovery week is added to the phase that week n-1 is in, later
+// phases move a week.
+func (p *TrainingPlan) InsertRecoveryWeek(n uint32, days []TrainingDay) error {
+	if n < 1 || n > p.WeekCount()+1 {
+		return fmt.Errorf("Week %d out of range", n)
+	}
+	for _, day := range days {
+		if day.Order < 1 || day.Order > 7 {
+			return fmt.Errorf("Day %d of the recovery week must be between 1 and 7", day.Order)
+		}
+	}
+
+	p.renumberWeeks(func(week uint32) uint32 {
+		if week >= n {
+			return week + 1
+		}
+		return week
+	})
+	for i, phase := range p.Phases {
+		switch {
+		case phase.FirstWeek >= n:
+			p.Phases[i].FirstWeek++
+			p.Phases[i].LastWeek++
+		case phase.LastWeek+1 >= n:
+			p.Phases[i].LastWeek++
+		}
+	}
+
+	for _, day := range days {
+		day.Order += p.StartOffset + 7*(n-1)
+		p.TrainingDays = append(p.TrainingDays, day)
+	}
+	sort.SliceStable(p.TrainingDays, func(a, b int) bool {
+		return p.TrainingDays[a].Order < p.TrainingDays[b].Order
+	})
+	p.Weeks = append(p.Weeks, PlanWeek{Number: n, Name: "Recovery week", Recovery: true})
+	sort.SliceStable(p.Weeks, func(a, b int) bool { return p.Weeks[a].Number < p.Weeks[b].Number })
+	if p.Duration > 0 {
+		p.Duration += 7
+	}
+	return nil
+}
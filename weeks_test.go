@@ -0,0 +1,141 @@
+package goworkouts
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func weekTestPlan() TrainingPlan {
+	day := func(order uint32) TrainingDay {
+		return TrainingDay{Order: order, Workouts: []Workout{{Name: string(rune('A' + order - 1))}}}
+	}
+	return TrainingPlan{
+		Name:         "Weeks",
+		Duration:     21,
+		TrainingDays: []TrainingDay{day(1), day(3), day(9), day(15), day(20)},
+		Weeks: []PlanWeek{
+			{Number: 1, Name: "Week 1 - Base"},
+			{Number: 2, Name: "Week 2 - Base"},
+			{Number: 3, Name: "Week 3 - Build"},
+		},
+		Phases: []PlanPhase{
+			{Name: "Base", FirstWeek: 1, LastWeek: 2},
+			{Name: "Build", FirstWeek: 3, LastWeek: 3},
+		},
+	}
+}
+
+func dayNames(days []TrainingDay) string {
+	s := ""
+	for _, day := range days {
+		s += day.Workouts[0].Name
+	}
+	return s
+}
+
+func TestDaysByWeek(t *testing.T) {
+	plan := weekTestPlan()
+	weeks := plan.DaysByWeek()
+	if len(weeks) != 3 || dayNames(weeks[0]) != "AC" || dayNames(weeks[1]) != "I" || dayNames(weeks[2]) != "OT" {
+		t.Errorf("Wrong weeks %v", weeks)
+	}
+	if dayNames(plan.DaysOfWeek(3)) != "OT" {
+		t.Errorf("Wrong days of week 3")
+	}
+
+	// the weeks start after the rest days of the start offset
+	plan.StartOffset = 2
+	plan.Duration = 23
+	weeks = plan.DaysByWeek()
+	if len(weeks) != 3 || dayNames(weeks[0]) != "ACI" || dayNames(weeks[1]) != "O" || dayNames(weeks[2]) != "T" {
+		t.Errorf("Wrong weeks with a start offset %v", weeks)
+	}
+}
+
+func TestMoveWeek(t *testing.T) {
+	plan := weekTestPlan()
+	err := plan.MoveWeek(1, 3)
+	if err != nil {
+		t.Fatalf("MoveWeek returned an error: %v", err)
+	}
+	want := []uint32{2, 8, 13, 15, 17}
+	for i, day := range plan.TrainingDays {
+		if day.Order != want[i] {
+			t.Errorf("Day %d: wanted order %v, got %v", i, want[i], day.Order)
+		}
+	}
+	if dayNames(plan.TrainingDays) != "IOTAC" {
+		t.Errorf("Wrong order of days %v", dayNames(plan.TrainingDays))
+	}
+	if plan.Week(3).Name != "Week 1 - Base" {
+		t.Errorf("Week description did not move")
+	}
+	err = plan.MoveWeek(1, 4)
+	if err == nil {
+		t.Errorf("Should have thrown an error")
+	}
+}
+
+func TestInsertRecoveryWeek(t *testing.T) {
+	plan := weekTestPlan()
+	recovery := []TrainingDay{{Order: 2, Workouts: []Workout{{Name: "R"}}}}
+	err := plan.InsertRecoveryWeek(3, recovery)
+	if err != nil {
+		t.Fatalf("InsertRecoveryWeek returned an error: %v", err)
+	}
+	if plan.Duration != 28 || dayNames(plan.TrainingDays) != "ACIROT" {
+		t.Errorf("Wrong plan after inserting a week: %v days, %v", plan.Duration, dayNames(plan.TrainingDays))
+	}
+	if plan.TrainingDays[3].Order != 16 || plan.TrainingDays[5].Order != 27 {
+		t.Errorf("Days not shifted")
+	}
+	if !plan.Week(3).Recovery || plan.Week(4).Name != "Week 3 - Build" {
+		t.Errorf("Wrong weeks %+v", plan.Weeks)
+	}
+	if plan.Phases[0].LastWeek != 3 || plan.Phases[1].FirstWeek != 4 || plan.Phases[1].LastWeek != 4 {
+		t.Errorf("Wrong phases %+v", plan.Phases)
+	}
+
+	// a plan without a duration keeps it unset
+	plan = weekTestPlan()
+	plan.Duration = 0
+	err = plan.InsertRecoveryWeek(1, nil)
+	if err != nil {
+		t.Fatalf("InsertRecoveryWeek returned an error: %v", err)
+	}
+	if plan.Duration != 0 {
+		t.Errorf("Wanted no duration, got %d", plan.Duration)
+	}
+}
+
+func TestPlanWeeksCompatible(t *testing.T) {
+	data, err := ioutil.ReadFile("RowsandallTPdummy.json")
+	if err != nil {
+		t.Fatalf("Could not read plan")
+	}
+	var plan TrainingPlan
+	err = json.Unmarshal(data, &plan)
+	if err != nil {
+		t.Fatalf("Could not parse plan: %v", err)
+	}
+	if len(plan.Weeks) != 0 || len(plan.TrainingDays) != 3 || plan.WeekCount() != 1 {
+		t.Errorf("Wrong plan %v weeks, %v days", plan.WeekCount(), len(plan.TrainingDays))
+	}
+
+	plan = weekTestPlan()
+	data, err = yaml.Marshal(plan)
+	if err != nil {
+		t.Fatalf("Could not convert plan to YAML")
+	}
+	var back TrainingPlan
+	err = yaml.Unmarshal(data, &back)
+	if err != nil {
+		t.Fatalf("Could not read plan from YAML: %v", err)
+	}
+	if len(back.Weeks) != 3 || back.Phases[1].Name != "Build" {
+		t.Errorf("Weeks and phases not kept: %+v", back)
+	}
+}
@@ -0,0 +1,177 @@
+package goworkouts
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tormoder/fit"
+)
+
+// The workout message has a wkt_description field (number 17) in newer
+// versions of the FIT profile than the fit package supports. It is written
+// and read on the raw records.
+const (
+	wktDescriptionField = 17
+	fitBaseString       = 0x07
+)
+
+// subSportMapping maps the sub sport names (as returned by fit.SubSport's
+// String method) to their values
+var subSportMapping = func() map[string]fit.SubSport {
+	m := map[string]fit.SubSport{}
+	for i := 0; i < 0xFF; i++ {
+		s := fit.SubSport(i)
+		name := s.String()
+		if !strings.HasPrefix(name, "SubSport(") {
+			m[name] = s
+		}
+	}
+	return m
+}()
+
+var poolLengthUnits = map[string]fit.DisplayMeasure{
+	"Metric":   fit.DisplayMeasureMetric,
+	"Statute":  fit.DisplayMeasureStatute,
+	"Nautical": fit.DisplayMeasureNautical,
+}
+
+// targetCapabilities are the sensors needed for each target type
+var targetCapabilities = map[string]fit.WorkoutCapabilities{
+	"Speed":        fit.WorkoutCapabilitiesSpeed,
+	"SpeedLap":     fit.WorkoutCapabilitiesSpeed,
+	"HeartRate":    fit.WorkoutCapabilitiesHeartRate,
+	"HeartRateLap": fit.WorkoutCapabilitiesHeartRate,
+	"Cadence":      fit.WorkoutCapabilitiesCadence,
+	"Power":        fit.WorkoutCapabilitiesPower,
+	"Power3s":      fit.WorkoutCapabilitiesPower,
+	"Power10s":     fit.WorkoutCapabilitiesPower,
+	"Power30s":     fit.WorkoutCapabilitiesPower,
+	"PowerLap":     fit.WorkoutCapabilitiesPower,
+	"Grade":        fit.WorkoutCapabilitiesGrade,
+	"Resistance":   fit.WorkoutCapabilitiesResistance,
+}
+
+// capabilities returns the capabilities of the workout. If they are not set
+// they follow from the steps: the sensors their targets and durations need,
+// Interval for workouts with repeats and Custom for custom targets.
+func (w *Workout) capabilities() fit.WorkoutCapabilities {
+	if w.Capabilities != 0 {
+		return fit.WorkoutCapabilities(w.Capabilities)
+	}
+	var c fit.WorkoutCapabilities
+	for _, step := range w.Steps {
+		if isRepeat(step) {
+			c |= fit.WorkoutCapabilitiesInterval
+			continue
+		}
+		c |= targetCapabilities[step.TargetType]
+		if step.TargetValue == 0 && step.CustomTargetValueHigh > 0 {
+			c |= fit.WorkoutCapabilitiesCustom
+		}
+		switch step.DurationType {
+		case "Distance":
+			c |= fit.WorkoutCapabilitiesDistance
+		case "HrLessThan", "HrGreaterThan":
+			c |= fit.WorkoutCapabilitiesHeartRate
+		case "PowerLessThan", "PowerGreaterThan":
+			c |= fit.WorkoutCapabilitiesPower
+		}
+	}
+	return c
+}
+
+// workoutMsg creates the workout message of the workout
+func (w *Workout) workoutMsg() *fit.WorkoutMsg {
+	msg := fit.NewWorkoutMsg()
+	msg.WktName = w.Name
+	msg.Sport = sportMapping[w.Sport]
+	msg.Capabilities = w.capabilities()
+	msg.NumValidSteps = uint16(len(w.Steps))
+	if subSport, ok := subSportMapping[w.SubSport]; ok {
+		msg.SubSport = subSport
+	}
+	if w.PoolLength > 0 {
+		msg.PoolLength = uint16(math.Round(w.PoolLength * 100))
+		msg.PoolLengthUnit = fit.DisplayMeasureMetric
+		if unit, ok := poolLengthUnits[w.PoolLengthUnit]; ok {
+			msg.PoolLengthUnit = unit
+		}
+	}
+	return msg
+}
+
+// readWorkoutMsg sets the fields of the workout from a workout message
+func (w *Workout) readWorkoutMsg(msg *fit.WorkoutMsg) {
+	w.Name = msg.WktName
+	// use sportMapping to get the sport name
+	for k, v := range sportMapping {
+		if v == msg.Sport {
+			w.Sport = k
+		}
+	}
+	if msg.SubSport != fit.SubSportInvalid {
+		w.SubSport = msg.SubSport.String()
+	}
+	if msg.Capabilities != fit.WorkoutCapabilitiesInvalid {
+		w.Capabilities = uint32(msg.Capabilities)
+	}
+	if msg.PoolLength != 0xFFFF {
+		w.PoolLength = float64(msg.PoolLength) / 100
+		if msg.PoolLengthUnit != fit.DisplayMeasureInvalid {
+			w.PoolLengthUnit = msg.PoolLengthUnit.String()
+		}
+	}
+}
+
+// setDescription writes the description as the wkt_description field of the
+// workout message
+func setDescription(f *rawFIT, description string) error {
+	if description == "" {
+		return nil
+	}
+	value := []byte(description)
+	if len(value) > 254 {
+		// FIT strings are at most 255 bytes, with the terminating zero
+		value = value[:254]
+		for !utf8.Valid(value) {
+			value = value[:len(value)-1]
+		}
+	}
+	value = append(value, 0)
+
+	found := false
+	for i, r := range f.Records {
+		if r.Def.Global == uint16(fit.MesgNumWorkout) {
+			err := f.Records[i].setField(wktDescriptionField, fitBaseString, value)
+			if err != nil {
+				return err
+			}
+			found = true
+		}
+	}
+	if !found {
+		return errors.New("No workout message")
+	}
+	return nil
+}
+
+// readDescription returns the wkt_description field of the workout message
+func readDescription(f *rawFIT) string {
+	for _, r := range f.Records {
+		if r.Def.Global != uint16(fit.MesgNumWorkout) {
+			continue
+		}
+		value, ok := r.field(wktDescriptionField)
+		if !ok {
+			return ""
+		}
+		if i := bytes.IndexByte(value, 0); i >= 0 {
+			value = value[:i]
+		}
+		return string(value)
+	}
+	return ""
+}
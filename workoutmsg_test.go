@@ -0,0 +1,95 @@
+package goworkouts
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tormoder/fit"
+)
+
+func TestWorkoutMsgRoundTrip(t *testing.T) {
+	w, err := ReadFit("testdata/fitsdk/WorkoutCustomTargetValues.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	w.Sport = "swimming"
+	w.SubSport = "LapSwimming"
+	w.PoolLength = 25
+	w.Description = "Easy swim with drills"
+
+	data, err := w.MarshalFIT(FITOptions{Reproducible: true})
+	if err != nil {
+		t.Fatalf("MarshalFIT returned an error: %v", err)
+	}
+	f, err := fit.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Could not decode file: %v", err)
+	}
+	wf, err := f.Workout()
+	if err != nil {
+		t.Fatalf("Not a workout file")
+	}
+	msg := wf.Workout
+	if msg.NumValidSteps != uint16(len(w.Steps)) || msg.SubSport != fit.SubSportLapSwimming || msg.PoolLength != 2500 || msg.PoolLengthUnit != fit.DisplayMeasureMetric {
+		t.Errorf("Wrong workout message %+v", msg)
+	}
+	wantCapabilities := fit.WorkoutCapabilitiesHeartRate | fit.WorkoutCapabilitiesPower | fit.WorkoutCapabilitiesCustom
+	if msg.Capabilities&wantCapabilities != wantCapabilities {
+		t.Errorf("Wrong capabilities %v", msg.Capabilities)
+	}
+
+	back, err := DecodeWorkout(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeWorkout returned an error: %v", err)
+	}
+	if back.Description != w.Description || back.SubSport != w.SubSport || back.PoolLength != 25 || back.PoolLengthUnit != "Metric" {
+		t.Errorf("Workout fields not read back: %+v", back)
+	}
+	if back.Capabilities != uint32(msg.Capabilities) {
+		t.Errorf("Capabilities not read back")
+	}
+
+	again, err := back.MarshalFIT(FITOptions{Reproducible: true})
+	if err != nil {
+		t.Fatalf("MarshalFIT returned an error: %v", err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("FIT -> Workout -> FIT changed the file")
+	}
+}
+
+func TestLongDescription(t *testing.T) {
+	w := Workout{Name: "Long", Sport: "rowing", Description: strings.Repeat("é", 200)}
+	data, err := w.MarshalFIT(FITOptions{})
+	if err != nil {
+		t.Fatalf("MarshalFIT returned an error: %v", err)
+	}
+	back, err := DecodeWorkout(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeWorkout returned an error: %v", err)
+	}
+	if len(back.Description) != 254 || !utf8.ValidString(back.Description) {
+		t.Errorf("Wrong description of %d bytes", len(back.Description))
+	}
+}
+
+func TestSetField(t *testing.T) {
+	r := rawRecord{
+		Def:  &rawDef{Fields: []rawField{{Num: 1, Size: 1}, {Num: 5, Size: 2}}},
+		Data: []byte{1, 5, 5},
+	}
+	r.setField(3, 0, []byte{3, 3, 3})
+	if !bytes.Equal(r.Data, []byte{1, 3, 3, 3, 5, 5}) || len(r.Def.Fields) != 3 || r.Def.Fields[1].Num != 3 {
+		t.Errorf("Field not inserted: %v %v", r.Data, r.Def.Fields)
+	}
+	r.setField(5, 0, []byte{6})
+	if !bytes.Equal(r.Data, []byte{1, 3, 3, 3, 6}) || r.Def.Fields[2].Size != 1 {
+		t.Errorf("Field not replaced: %v %v", r.Data, r.Def.Fields)
+	}
+	value, ok := r.field(3)
+	if !ok || !bytes.Equal(value, []byte{3, 3, 3}) {
+		t.Errorf("Wrong field value %v", value)
+	}
+}
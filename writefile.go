@@ -0,0 +1,141 @@
+package goworkouts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tormoder/fit"
+)
+
+// OverwritePolicy says what WriteFile does when the file already exists
+type OverwritePolicy int
+
+const (
+	// OverwriteFail returns ErrFileExists and leaves the file alone
+	OverwriteFail OverwritePolicy = iota
+	// OverwriteReplace replaces the file
+	OverwriteReplace
+	// OverwriteVersion writes to the first free name with a numeric
+	// suffix, like workout-1.fit, workout-2.fit
+	OverwriteVersion
+)
+
+// maxVersions is the highest suffix OverwriteVersion tries
+const maxVersions = 10000
+
+// WriteOptions set how files are written
+type WriteOptions struct {
+	Mode      os.FileMode // defaults to 0644
+	Overwrite OverwritePolicy
+}
+
+// versionName returns path with suffix n before the extension
+func versionName(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%v-%d%v", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// syncDir flushes the directory entry of a renamed file. Not every system
+// can sync a directory, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// WriteFile writes data to path and returns the name of the written file,
+// which differs from path with OverwriteVersion. The data is written to a
+// temporary file in the same directory, synced and then moved in place, so
+// readers never see a partly written file and a failed write leaves nothing
+// behind. Moving the file in place is atomic, also when several goroutines or
+// processes write to the same path.
+func WriteFile(path string, data []byte, opts WriteOptions) (string, error) {
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	// removing fails once the file is moved in place
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if opts.Overwrite == OverwriteReplace {
+		err = os.Rename(tmp.Name(), path)
+		if err != nil {
+			return "", err
+		}
+		syncDir(dir)
+		return path, nil
+	}
+
+	// a hard link fails if the name is taken, which makes claiming a name
+	// atomic
+	for n := 0; n < maxVersions; n++ {
+		name := versionName(path, n)
+		err = os.Link(tmp.Name(), name)
+		if err == nil {
+			syncDir(dir)
+			return name, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+		if opts.Overwrite != OverwriteVersion {
+			return "", fmt.Errorf("%w: %v", ErrFileExists, path)
+		}
+	}
+	return "", fmt.Errorf("%w: %v and %d versions", ErrFileExists, path, maxVersions)
+}
+
+// WriteFitWithOptions encodes a FIT file with EncodeFit and writes it with
+// WriteFile. It returns the name of the written file.
+func WriteFitWithOptions(path string, f *fit.File, opts WriteOptions) (string, error) {
+	var buf bytes.Buffer
+	err := EncodeFit(&buf, f)
+	if err != nil {
+		return "", err
+	}
+	return WriteFile(path, buf.Bytes(), opts)
+}
+
+// WriteWorkout encodes the workout with MarshalFIT and the default FITOptions
+// and writes it with WriteFile. Unlike ToFIT and WriteFit, it keeps the
+// description and the FIT extensions of the workout. It returns the name of
+// the written file.
+func WriteWorkout(path string, w *Workout, opts WriteOptions) (string, error) {
+	data, err := w.MarshalFIT(FITOptions{})
+	if err != nil {
+		return "", err
+	}
+	return WriteFile(path, data, opts)
+}
@@ -0,0 +1,176 @@
+package goworkouts
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// dirNames returns the names of the files in dir
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Could not read %v", dir)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workout.fit")
+
+	name, err := WriteFile(path, []byte("a longer first version"), WriteOptions{})
+	if err != nil || name != path {
+		t.Fatalf("WriteFile returned %v, %v", name, err)
+	}
+	_, err = WriteFile(path, []byte("second"), WriteOptions{})
+	if !errors.Is(err, ErrFileExists) {
+		t.Errorf("Got %v, wanted ErrFileExists", err)
+	}
+	_, err = WriteFile(path, []byte("short"), WriteOptions{Overwrite: OverwriteReplace})
+	if err != nil {
+		t.Fatalf("WriteFile returned an error: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "short" {
+		t.Errorf("Got %q after replacing the file, wanted %q", data, "short")
+	}
+
+	names := dirNames(t, dir)
+	if len(names) != 1 {
+		t.Errorf("Temporary files were left behind: %v", names)
+	}
+}
+
+func TestWriteFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no file mode bits")
+	}
+	path := filepath.Join(t.TempDir(), "workout.fit")
+	_, err := WriteFile(path, []byte("data"), WriteOptions{Mode: 0600})
+	if err != nil {
+		t.Fatalf("WriteFile returned an error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Could not stat %v", path)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Got mode %v, wanted 0600", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileVersions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workout.fit")
+
+	const n = 20
+	var wg sync.WaitGroup
+	names := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			names[i], errs[i] = WriteFile(path, []byte{byte(i)}, WriteOptions{Overwrite: OverwriteVersion})
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for i := range names {
+		if errs[i] != nil {
+			t.Fatalf("WriteFile returned an error: %v", errs[i])
+		}
+		if seen[names[i]] {
+			t.Errorf("%v was written twice", names[i])
+		}
+		seen[names[i]] = true
+		data, err := ioutil.ReadFile(names[i])
+		if err != nil || !bytes.Equal(data, []byte{byte(i)}) {
+			t.Errorf("%v has the wrong content", names[i])
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !seen[versionName(path, i)] {
+			t.Errorf("%v was not written", versionName(path, i))
+		}
+	}
+	if len(dirNames(t, dir)) != n {
+		t.Errorf("Temporary files were left behind: %v", dirNames(t, dir))
+	}
+}
+
+func TestWriteFitOverwrite(t *testing.T) {
+	long, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	short, err := ReadFit("testdata/repeats.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	path := filepath.Join(t.TempDir(), "workout.fit")
+	for _, w := range []Workout{long, short} {
+		f, err := w.ToFIT()
+		if err != nil {
+			t.Fatalf("ToFIT returned an error")
+		}
+		_, err = WriteFit(path, f, true)
+		if err != nil {
+			t.Fatalf("WriteFit returned an error: %v", err)
+		}
+	}
+	back, err := ReadFit(path)
+	if err != nil {
+		t.Fatalf("Could not read the replaced file: %v", err)
+	}
+	checkSameSteps(t, "replaced file", short, back)
+}
+
+func TestWriteWorkoutDescription(t *testing.T) {
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	w.Description = "Sprints with long rests"
+	path := filepath.Join(t.TempDir(), "workout.fit")
+	_, err = WriteWorkout(path, &w, WriteOptions{})
+	if err != nil {
+		t.Fatalf("WriteWorkout returned an error: %v", err)
+	}
+	back, err := ReadFit(path)
+	if err != nil {
+		t.Fatalf("ReadFit returned an error: %v", err)
+	}
+	if back.Description != w.Description {
+		t.Errorf("Wanted description %q, got %q", w.Description, back.Description)
+	}
+	checkSameSteps(t, "written workout", w, back)
+
+	// FIT -> Workout -> FIT through WriteWorkout keeps the description
+	again := filepath.Join(t.TempDir(), "again.fit")
+	_, err = WriteWorkout(again, &back, WriteOptions{})
+	if err != nil {
+		t.Fatalf("WriteWorkout returned an error: %v", err)
+	}
+	back, err = ReadFit(again)
+	if err != nil || back.Description != w.Description {
+		t.Errorf("Description lost on the second round trip: %v", err)
+	}
+
+	_, err = WriteWorkout(path, &w, WriteOptions{})
+	if !errors.Is(err, ErrFileExists) {
+		t.Errorf("Expected ErrFileExists, got %v", err)
+	}
+}
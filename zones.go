@@ -0,0 +1,277 @@
+package goworkouts
+
+import (
+	"math"
+	"time"
+)
+
+// ZoneDistribution is the planned time in each power and heart rate zone.
+// Index 0 of Power and HR is zone 1.
+type ZoneDistribution struct {
+	Power []time.Duration `json:"power" yaml:"power"`
+	HR    []time.Duration `json:"hr" yaml:"hr"`
+	// Unzoned is the time of steps that cannot be put in a power or heart
+	// rate zone, like open steps or steps with a speed target
+	Unzoned time.Duration `json:"unzoned" yaml:"unzoned"`
+}
+
+// newZoneDistribution returns an empty distribution with the zones of the
+// profile
+func newZoneDistribution(profile AthleteProfile) ZoneDistribution {
+	return ZoneDistribution{
+		Power: make([]time.Duration, len(profile.powerZones())),
+		HR:    make([]time.Duration, len(profile.hrZones())),
+	}
+}
+
+// Add adds the time of other to z
+func (z *ZoneDistribution) Add(other ZoneDistribution) {
+	add := func(to []time.Duration, from []time.Duration) []time.Duration {
+		for len(to) < len(from) {
+			to = append(to, 0)
+		}
+		for i, d := range from {
+			to[i] += d
+		}
+		return to
+	}
+	z.Power = add(z.Power, other.Power)
+	z.HR = add(z.HR, other.HR)
+	z.Unzoned += other.Unzoned
+}
+
+// Total returns the total time of the distribution, including unzoned time
+func (z ZoneDistribution) Total() time.Duration {
+	total := z.Unzoned
+	for _, d := range z.Power {
+		total += d
+	}
+	for _, d := range z.HR {
+		total += d
+	}
+	return total
+}
+
+// ThreeZones combines the power and heart rate zones in the three zone
+// intensity model used for polarized training: low (power zones 1-2, HR zones
+// 1-2), moderate (power zones 3-4, HR zone 3) and high (higher zones).
+// Unzoned time is left out.
+func (z ZoneDistribution) ThreeZones() [3]time.Duration {
+	var three [3]time.Duration
+	for i, d := range z.Power {
+		switch {
+		case i < 2:
+			three[0] += d
+		case i < 4:
+			three[1] += d
+		default:
+			three[2] += d
+		}
+	}
+	for i, d := range z.HR {
+		switch {
+		case i < 2:
+			three[0] += d
+		case i < 3:
+			three[1] += d
+		default:
+			three[2] += d
+		}
+	}
+	return three
+}
+
+// PolarizationIndex returns log10(Z1 / Z2 * Z3 * 100) of the fractions of
+// time in the three zones of ThreeZones (Treff et al., 2019). A value above 2
+// is a polarized distribution. A Z2 of 0 is counted as 0.01, without any time
+// in Z3 the index is 0.
+func (z ZoneDistribution) PolarizationIndex() float64 {
+	three := z.ThreeZones()
+	total := three[0] + three[1] + three[2]
+	if total == 0 || three[2] == 0 {
+		return 0
+	}
+	z1 := float64(three[0]) / float64(total)
+	z2 := float64(three[1]) / float64(total)
+	z3 := float64(three[2]) / float64(total)
+	if z2 == 0 {
+		z2 = 0.01
+	}
+	pi := math.Log10(z1 / z2 * z3 * 100)
+	if pi < 0 || math.IsInf(pi, 0) {
+		return 0
+	}
+	return pi
+}
+
+// spread adds d to the zones, divided evenly over the range low-high (both
+// fractions of the threshold the zones are defined on)
+func spread(to []time.Duration, zones []float64, low float64, high float64, d time.Duration) {
+	if high < low {
+		low, high = high, low
+	}
+	if high-low < 1e-9 {
+		to[zoneOf(zones, low)-1] += d
+		return
+	}
+	var added time.Duration
+	last := 0
+	prev := 0.0
+	for i, limit := range zones {
+		overlap := math.Min(high, limit) - math.Max(low, prev)
+		if i == len(zones)-1 {
+			overlap = high - math.Max(low, prev)
+		}
+		if overlap > 0 {
+			part := time.Duration(float64(d) * overlap / (high - low)).Round(time.Millisecond)
+			to[i] += part
+			added += part
+			last = i
+		}
+		prev = limit
+	}
+	// rounding to milliseconds
+	to[last] += d - added
+}
+
+// zoneKind is the kind of zones a step is counted in
+type zoneKind int
+
+const (
+	unzoned zoneKind = iota
+	powerZoned
+	hrZoned
+)
+
+// primaryKind returns the kind of zones most of the targets of the steps are
+// in. Warmup and cooldown steps without a target are counted in these zones.
+func primaryKind(steps []WorkoutStep) zoneKind {
+	power := 0
+	hr := 0
+	for _, step := range steps {
+		switch {
+		case isPowerTarget(step.TargetType):
+			power++
+		case isHRTarget(step.TargetType):
+			hr++
+		}
+	}
+	switch {
+	case power == 0 && hr == 0:
+		return unzoned
+	case power >= hr:
+		return powerZoned
+	}
+	return hrZoned
+}
+
+// isRamp returns true for steps that ramp the intensity up or down
+func isRamp(step WorkoutStep) bool {
+	return step.Intensity == "Warmup" || step.Intensity == "Cooldown"
+}
+
+// addStep adds the duration of a step to the zones
+func (z *ZoneDistribution) addStep(profile AthleteProfile, step WorkoutStep, kind zoneKind, d time.Duration) {
+	var to []time.Duration
+	var zones []float64
+	var low, high float64
+	ok := false
+	hasTarget := step.TargetValue > 0 || step.CustomTargetValueHigh > 0
+
+	switch {
+	case isPowerTarget(step.TargetType) && hasTarget:
+		to, zones = z.Power, profile.powerZones()
+		low, high, ok = profile.powerRange(step)
+	case isHRTarget(step.TargetType) && hasTarget:
+		to, zones = z.HR, profile.hrZones()
+		low, high, ok = profile.hrRange(step)
+	case isRamp(step) && kind != unzoned:
+		// ramp Z1-Z2, like ToIntervals
+		to, zones = z.Power, profile.powerZones()
+		if kind == hrZoned {
+			to, zones = z.HR, profile.hrZones()
+		}
+		if len(to) < 2 {
+			// a single zone holds the whole ramp
+			to[0] += d
+			return
+		}
+		to[0] += d / 2
+		to[1] += d - d/2
+		return
+	}
+	if !ok {
+		z.Unzoned += d
+		return
+	}
+	if step.TargetValue > 0 || !isRamp(step) {
+		mid := (low + high) / 2
+		low, high = mid, mid
+	}
+	spread(to, zones, low, high, d)
+}
+
+// ZoneDistribution returns the planned time of the workout in each power and
+// heart rate zone of the profile, with the repeats expanded. Steps with a
+// target range are counted in the zone of the middle of the range, except
+// warmup and cooldown steps, which ramp through the whole range. Warmup and
+// cooldown steps without a target are split over zones 1 and 2.
+func (w *Workout) ZoneDistribution(profile AthleteProfile) (ZoneDistribution, error) {
+	z := newZoneDistribution(profile)
+	steps, err := w.Expand()
+	if err != nil {
+		return z, err
+	}
+	kind := primaryKind(steps)
+	speed := profile.speed(w)
+	for _, step := range steps {
+		d, err := StepDuration(step, speed)
+		if err != nil {
+			return z, err
+		}
+		z.addStep(profile, step, kind, d)
+	}
+	return z, nil
+}
+
+// WeekZoneDistribution is the zone distribution of a week of a plan
+type WeekZoneDistribution struct {
+	Week              int              `json:"week" yaml:"week"` // zero based
+	Zones             ZoneDistribution `json:"zones" yaml:"zones"`
+	PolarizationIndex float64          `json:"polarizationIndex" yaml:"polarizationIndex"`
+}
+
+// PlanZoneDistribution is the zone distribution of a plan, per week and in
+// total
+type PlanZoneDistribution struct {
+	Weeks             []WeekZoneDistribution `json:"weeks" yaml:"weeks"`
+	Total             ZoneDistribution       `json:"total" yaml:"total"`
+	PolarizationIndex float64                `json:"polarizationIndex" yaml:"polarizationIndex"`
+}
+
+// ZoneDistribution adds up the zone distributions of the workouts of the plan
+// per week and computes their polarization index
+func (p *TrainingPlan) ZoneDistribution(profile AthleteProfile) (PlanZoneDistribution, error) {
+	weeks := int(p.WeekCount())
+
+	plan := PlanZoneDistribution{Total: newZoneDistribution(profile)}
+	for i := 0; i < weeks; i++ {
+		plan.Weeks = append(plan.Weeks, WeekZoneDistribution{Week: i, Zones: newZoneDistribution(profile)})
+	}
+	for _, day := range p.TrainingDays {
+		week := &plan.Weeks[p.dayWeek(day)]
+		for i := range day.Workouts {
+			z, err := day.Workouts[i].ZoneDistribution(profile)
+			if err != nil {
+				return plan, err
+			}
+			week.Zones.Add(z)
+		}
+	}
+	for i := range plan.Weeks {
+		plan.Weeks[i].PolarizationIndex = plan.Weeks[i].Zones.PolarizationIndex()
+		plan.Total.Add(plan.Weeks[i].Zones)
+	}
+	plan.PolarizationIndex = plan.Total.PolarizationIndex()
+	return plan, nil
+}
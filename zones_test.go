@@ -0,0 +1,102 @@
+package goworkouts
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestZoneDistribution(t *testing.T) {
+	w, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	z, err := w.ZoneDistribution(AthleteProfile{FTP: 250})
+	if err != nil {
+		t.Fatalf("ZoneDistribution returned an error: %v", err)
+	}
+	// warmup and cooldown ramp through zones 1 and 2, 24 sprints in zone 5
+	want := []time.Duration{10 * time.Minute, 10 * time.Minute, 0, 0, 18 * time.Minute, 0, 0}
+	for i, d := range z.Power {
+		if d != want[i] {
+			t.Errorf("Power zone %d: wanted %v, got %v", i+1, want[i], d)
+		}
+	}
+	if z.Unzoned != 30*time.Minute {
+		t.Errorf("Wanted 30m of rest unzoned, got %v", z.Unzoned)
+	}
+
+	w, err = ReadFit("testdata/fitsdk/WorkoutCustomTargetValues.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	z, err = w.ZoneDistribution(AthleteProfile{FTP: 250, MaxHR: 190, Speed: 5})
+	if err != nil {
+		t.Fatalf("ZoneDistribution returned an error: %v", err)
+	}
+	if z.HR[0] != time.Minute {
+		t.Errorf("Wanted 1m in HR zone 1, got %v", z.HR[0])
+	}
+	// 300-310W and 260-270W for 100s each
+	if z.Power[5] != 100*time.Second || z.Power[4] != 100*time.Second {
+		t.Errorf("Wrong power zones %v", z.Power)
+	}
+}
+
+func TestZoneDistributionOneZone(t *testing.T) {
+	w := Workout{Sport: "cycling", Steps: []WorkoutStep{
+		{DurationType: "Time", DurationValue: 600000, TargetType: "Open", Intensity: "Warmup"},
+		{MessageIndex: 1, DurationType: "Time", DurationValue: 600000, TargetType: "Power", CustomTargetValueLow: 1200, CustomTargetValueHigh: 1250, Intensity: "Active"},
+	}}
+	z, err := w.ZoneDistribution(AthleteProfile{FTP: 250, PowerZones: []float64{2.0}})
+	if err != nil {
+		t.Fatalf("ZoneDistribution returned an error: %v", err)
+	}
+	if len(z.Power) != 1 || z.Power[0] != 20*time.Minute {
+		t.Errorf("Wanted 20m in the only power zone, got %v", z.Power)
+	}
+}
+
+func TestRampSpread(t *testing.T) {
+	to := make([]time.Duration, 5)
+	spread(to, DefaultHRZones, 0.5, 0.8, 30*time.Minute)
+	want := []time.Duration{10 * time.Minute, 10 * time.Minute, 10 * time.Minute, 0, 0}
+	for i := range to {
+		if to[i] != want[i] {
+			t.Errorf("Zone %d: wanted %v, got %v", i+1, want[i], to[i])
+		}
+	}
+}
+
+func TestPlanZoneDistribution(t *testing.T) {
+	easy := Workout{Name: "easy", Steps: []WorkoutStep{
+		{DurationType: "Time", DurationValue: 3600000, TargetType: "HeartRate", TargetValue: 2},
+	}}
+	hard, err := ReadFit("testdata/nestedrepeats2.fit")
+	if err != nil {
+		t.Fatalf("ReadFit returned an error")
+	}
+	plan := TrainingPlan{Name: "polarized", Duration: 14, TrainingDays: []TrainingDay{
+		{Order: 1, Workouts: []Workout{easy}},
+		{Order: 3, Workouts: []Workout{hard}},
+		{Order: 9, Workouts: []Workout{easy, easy}},
+	}}
+	z, err := plan.ZoneDistribution(AthleteProfile{FTP: 250})
+	if err != nil {
+		t.Fatalf("ZoneDistribution returned an error: %v", err)
+	}
+	if len(z.Weeks) != 2 {
+		t.Fatalf("Wanted 2 weeks, got %v", len(z.Weeks))
+	}
+	if z.Weeks[1].Zones.HR[1] != 2*time.Hour || z.Weeks[1].PolarizationIndex != 0 {
+		t.Errorf("Wrong second week %+v", z.Weeks[1])
+	}
+	// week 1: 80m low, 0 moderate, 18m high
+	pi := math.Log10(80. / 98 / 0.01 * 18. / 98 * 100)
+	if math.Abs(z.Weeks[0].PolarizationIndex-pi) > 1e-9 {
+		t.Errorf("Wanted polarization index %v, got %v", pi, z.Weeks[0].PolarizationIndex)
+	}
+	if z.Total.Total() != 3*time.Hour+68*time.Minute {
+		t.Errorf("Wrong total time %v", z.Total.Total())
+	}
+}